@@ -8,10 +8,16 @@ import (
 	"syscall"
 
 	"goRag/internal/api"
+	"goRag/internal/chunker"
 	"goRag/internal/embedding"
+	"goRag/internal/envconfig"
 	"goRag/internal/llm"
+	"goRag/internal/prompt"
 	"goRag/internal/rag"
+	"goRag/internal/ranker"
+	"goRag/internal/resilience"
 	"goRag/internal/retriever"
+	"goRag/internal/session"
 )
 
 func main() {
@@ -22,30 +28,137 @@ func main() {
 
 	// 1. 初始化嵌入服务
 
+	// RAG_EMBEDDER=openai 时使用 OpenAI 兼容的 /v1/embeddings 接口，默认使用 Ollama
+	// 请求后端时按 RAG_RETRY_* 重试，按 RAG_BREAKER_* 熔断，熔断器状态在健康检查中报告
+	var breakers []*resilience.Breaker
 	var embedder embedding.Embedder
-	embedder, err := embedding.NewOllamaEmbedder(embedding.NewOllamaEmbedderConfigFromEnv())
+	var err error
+	switch provider := os.Getenv("RAG_EMBEDDER"); provider {
+	case "", "ollama":
+		ollamaEmbedder, err := embedding.NewOllamaEmbedder(mustEnv(embedding.NewOllamaEmbedderConfigFromEnv()))
+		if err != nil {
+			log.Fatalf("Failed to create embedder: %v", err)
+		}
+		embedder = ollamaEmbedder
+		breakers = append(breakers, ollamaEmbedder.Breaker())
+	case "openai":
+		openAIEmbedder, err := embedding.NewOpenAIEmbedder(mustEnv(embedding.NewOpenAIEmbedderConfigFromEnv()))
+		if err != nil {
+			log.Fatalf("Failed to create embedder: %v", err)
+		}
+		embedder = openAIEmbedder
+		breakers = append(breakers, openAIEmbedder.Breaker())
+	default:
+		log.Fatalf("Unknown embedder %q", provider)
+	}
+	log.Printf("✓ Using embedding model %s (dimension=%d)", embedder.GetModelName(), embedder.GetDimension())
+	// 批量嵌入时按条数和字符数分批、并发请求，避免单个超大请求超时
+	batchConfig := mustEnv(embedding.NewBatchConfigFromEnv())
+	embedder, err = embedding.NewBatchingEmbedder(embedder, batchConfig)
 	if err != nil {
-		log.Fatalf("Failed to create embedder: %v", err)
+		log.Fatalf("Failed to create batching embedder: %v", err)
+	}
+	log.Printf("✓ Embedding batches: %d texts / %d chars, concurrency %d",
+		batchConfig.MaxBatchSize, batchConfig.MaxBatchChars, batchConfig.Concurrency)
+	// 嵌入缓存：默认在内存中缓存 10000 条向量，设置 RAG_EMBED_CACHE_DIR 后同时持久化到磁盘
+	// RAG_EMBED_CACHE_SIZE=0 且未设置目录时不使用缓存
+	var cachedEmbedder *embedding.CachedEmbedder
+	if cacheConfig := mustEnv(embedding.NewCacheConfigFromEnv()); cacheConfig.Capacity > 0 || cacheConfig.Dir != "" {
+		cachedEmbedder, err = embedding.NewCachedEmbedder(embedder, cacheConfig)
+		if err != nil {
+			log.Fatalf("Failed to create embedding cache: %v", err)
+		}
+		embedder = cachedEmbedder
+		log.Printf("✓ Using embedding cache (capacity=%d, dir=%q)", cacheConfig.Capacity, cacheConfig.Dir)
+	}
+	// 按模型给查询和文档加上不同的指令前缀（如 qwen3-embedding 的查询指令），包在缓存外层使缓存键包含前缀
+	if instruction := embedding.NewInstructionFromEnv(embedder.GetModelName()); !instruction.IsZero() {
+		embedder, err = embedding.NewInstructionEmbedder(embedder, instruction)
+		if err != nil {
+			log.Fatalf("Failed to create instruction embedder: %v", err)
+		}
+		log.Printf("✓ Using embedding instructions (query=%q, document=%q)", instruction.Query, instruction.Document)
 	}
 	embeddingService := embedding.NewService(embedder)
 	log.Println("✓ Embedding service initialized")
 
 	// 2. 初始化检索服务
-	memoryRetriever, err := retriever.NewMemoryRetriever(embedder)
+	// 设置 RAG_DATA_DIR 后文档和向量会持久化到磁盘，重启时无需重新嵌入
+	var retrieverOpts []retriever.MemoryOption
+	if dataDir := os.Getenv("RAG_DATA_DIR"); dataDir != "" {
+		store, err := retriever.OpenFileStore(dataDir)
+		if err != nil {
+			log.Fatalf("Failed to open store: %v", err)
+		}
+		compactThreshold := mustEnv(envconfig.Int("RAG_COMPACT_THRESHOLD", 1000))
+		retrieverOpts = append(retrieverOpts, retriever.WithFileStore(store, compactThreshold))
+		log.Printf("✓ Using persistent store at %s", dataDir)
+	}
+	// RAG_INDEX=hnsw 时使用近似最近邻索引，适合数万条以上的语料
+	if os.Getenv("RAG_INDEX") == "hnsw" {
+		hnswConfig := retriever.DefaultHNSWConfig()
+		hnswConfig.M = mustEnv(envconfig.Int("RAG_HNSW_M", hnswConfig.M))
+		hnswConfig.EfConstruction = mustEnv(envconfig.Int("RAG_HNSW_EF_CONSTRUCTION", hnswConfig.EfConstruction))
+		hnswConfig.EfSearch = mustEnv(envconfig.Int("RAG_HNSW_EF_SEARCH", hnswConfig.EfSearch))
+		retrieverOpts = append(retrieverOpts, retriever.WithIndex(retriever.NewHNSWIndex(hnswConfig)))
+		log.Printf("✓ Using HNSW index (M=%d, efConstruction=%d, efSearch=%d)",
+			hnswConfig.M, hnswConfig.EfConstruction, hnswConfig.EfSearch)
+	}
+	// RAG_RETRIEVAL=hybrid 时同时维护 BM25 索引，融合向量和关键词检索结果
+	hybrid := os.Getenv("RAG_RETRIEVAL") == "hybrid"
+	if hybrid {
+		retrieverOpts = append(retrieverOpts, retriever.WithLexicalIndex(retriever.NewBM25Index(0, 0)))
+	}
+	// RAG_CHUNKER 设置后文档先切分成分块再嵌入，如 "recursive:800:100"、"markdown:800:100"
+	if spec := os.Getenv("RAG_CHUNKER"); spec != "" {
+		splitter, err := chunker.Parse(spec)
+		if err != nil {
+			log.Fatalf("Failed to create chunker: %v", err)
+		}
+		retrieverOpts = append(retrieverOpts, retriever.WithChunker(splitter))
+		log.Printf("✓ Using chunker: %s", spec)
+	}
+	memoryRetriever, err := retriever.NewMemoryRetriever(embedder, retrieverOpts...)
 	if err != nil {
 		log.Fatalf("Failed to create memory retriever: %v", err)
 	}
-	retrieverService := retriever.NewService(memoryRetriever)
+
+	var activeRetriever retriever.Retriever = memoryRetriever
+	if hybrid {
+		hybridConfig := retriever.DefaultHybridConfig()
+		if v := os.Getenv("RAG_FUSION"); v != "" {
+			hybridConfig.Fusion = v
+		}
+		hybridConfig.VectorWeight = mustEnv(envconfig.Float("RAG_HYBRID_VECTOR_WEIGHT", hybridConfig.VectorWeight))
+		hybridConfig.LexicalWeight = mustEnv(envconfig.Float("RAG_HYBRID_LEXICAL_WEIGHT", hybridConfig.LexicalWeight))
+		hybridRetriever, err := retriever.NewHybridRetriever(memoryRetriever, hybridConfig)
+		if err != nil {
+			log.Fatalf("Failed to create hybrid retriever: %v", err)
+		}
+		activeRetriever = hybridRetriever
+		log.Printf("✓ Using hybrid retrieval (fusion=%s)", hybridConfig.Fusion)
+	}
+	retrieverService := retriever.NewService(activeRetriever)
 	log.Println("✓ Retriever service initialized")
 
 	// 3. 初始化 LLM 服务
-	// 优先尝试使用 OpenAI，如果没有配置或连接失败，则使用 Mock LLM
+	// 设置了 OPENAI_API_KEY 或 OPENAI_BASE_URL 时使用 OpenAI 兼容接口，否则使用 Ollama，Ollama 创建失败时使用 Mock LLM
 	var llmImpl llm.LLM
-	ollamaConfig := llm.NewOllamaConfigFromEnv()
-	if ollamaConfig != nil {
+	if openAIConfig := mustEnv(llm.NewOpenAIConfigFromEnv()); openAIConfig != nil {
+		openAI, err := llm.NewOpenAI(openAIConfig)
+		if err != nil {
+			log.Fatalf("Failed to create OpenAI LLM: %v", err)
+		}
+		llmImpl = openAI
+		breakers = append(breakers, openAI.Breaker())
+		log.Println("✓ LLM service initialized (using OpenAI)")
+	}
+	if llmImpl == nil {
+		ollamaConfig := mustEnv(llm.NewOllamaConfigFromEnv())
 		ollamaLLM, err := llm.NewOllama(ollamaConfig)
 		if err == nil && ollamaLLM != nil {
 			llmImpl = ollamaLLM
+			breakers = append(breakers, ollamaLLM.Breaker())
 			log.Println("✓ LLM service initialized (using Ollama)")
 		}
 	}
@@ -56,15 +169,60 @@ func main() {
 	llmService := llm.NewService(llmImpl)
 
 	// 4. 初始化 RAG 服务
+	// RAG_RANKER 配置排序链，例如 "rerank:0.3,simple"
+	var ragOpts []rag.Option
+	if spec := os.Getenv("RAG_RANKER"); spec != "" {
+		r, err := ranker.ParseChain(spec, llmImpl)
+		if err != nil {
+			log.Fatalf("Failed to create ranker: %v", err)
+		}
+		ragOpts = append(ragOpts, rag.WithRanker(r))
+		log.Printf("✓ Using ranker chain: %s", spec)
+	}
+	if n, ok := mustLookupEnv(envconfig.LookupInt("RAG_CANDIDATE_MULTIPLIER")); ok {
+		ragOpts = append(ragOpts, rag.WithCandidateMultiplier(n))
+	}
+	if dir := os.Getenv("RAG_PROMPT_DIR"); dir != "" {
+		registry, err := prompt.LoadRegistry(dir)
+		if err != nil {
+			log.Fatalf("Failed to load prompt templates: %v", err)
+		}
+		ragOpts = append(ragOpts, rag.WithPromptRegistry(registry))
+		log.Printf("✓ Loaded prompt templates: %v", registry.Names())
+	}
+	if os.Getenv("RAG_ANSWER_WITHOUT_CONTEXT") == "true" {
+		ragOpts = append(ragOpts, rag.WithAnswerWithoutContext(true))
+	}
+	if n, ok := mustLookupEnv(envconfig.LookupInt("RAG_CONTEXT_WINDOW")); ok {
+		if n > 0 {
+			budget := rag.DefaultContextBudget()
+			budget.DefaultWindow = n
+			budget.ModelWindows = nil
+			ragOpts = append(ragOpts, rag.WithContextBudget(budget))
+		}
+	}
+	if n, ok := mustLookupEnv(envconfig.LookupInt("RAG_EXPAND_WINDOW")); ok {
+		ragOpts = append(ragOpts, rag.WithExpandWindow(n))
+	}
+	if n, ok := mustLookupEnv(envconfig.LookupInt("RAG_HISTORY_MESSAGES")); ok {
+		ragOpts = append(ragOpts, rag.WithHistoryMessages(n))
+	}
+	// 会话保存在内存中：超过 RAG_SESSION_MAX 个时淘汰最久未使用的会话，闲置超过 RAG_SESSION_TTL 后过期
+	sessionStore := session.NewMemoryStore(session.DefaultMaxMessages,
+		session.WithMaxSessions(mustEnv(envconfig.Int("RAG_SESSION_MAX", session.DefaultMaxSessions))),
+		session.WithIdleTTL(mustEnv(envconfig.Duration("RAG_SESSION_TTL", session.DefaultIdleTTL))),
+	)
+	ragOpts = append(ragOpts, rag.WithSessionStore(sessionStore))
 	ragService := rag.NewRAGService(
 		embeddingService,
 		retrieverService,
 		llmService,
+		ragOpts...,
 	)
 	log.Println("✓ RAG service initialized")
 
 	// 5. 初始化 API 服务器
-	apiServer := api.NewServer(ragService)
+	apiServer := api.NewServer(ragService, api.WithBreakers(breakers...))
 	log.Println("✓ API server initialized")
 
 	// 启动服务器
@@ -77,8 +235,13 @@ func main() {
 	log.Println("Server is running on http://localhost:8080")
 	log.Println("API endpoints:")
 	log.Println("  POST   /api/v1/query      - Query documents")
+	log.Println("  POST   /api/v1/query/stream - Query documents (SSE)")
 	log.Println("  POST   /api/v1/documents   - Add documents")
 	log.Println("  DELETE /api/v1/documents  - Delete document")
+	log.Println("  POST   /api/v1/conversations - Create conversation")
+	log.Println("  GET    /api/v1/conversations - List conversations")
+	log.Println("  POST   /api/v1/conversations/:id/messages - Continue conversation")
+	log.Println("  DELETE /api/v1/conversations/:id - Delete conversation")
 	log.Println("  GET    /api/v1/health     - Health check")
 
 	// 等待中断信号
@@ -88,4 +251,31 @@ func main() {
 
 	log.Println("Shutting down server...")
 	cancel()
+
+	if err := memoryRetriever.Close(); err != nil {
+		log.Printf("Failed to close retriever store: %v", err)
+	}
+	if cachedEmbedder != nil {
+		stats := cachedEmbedder.Stats()
+		log.Printf("Embedding cache: %d hits, %d disk hits, %d misses", stats.Hits, stats.DiskHits, stats.Misses)
+		if err := cachedEmbedder.Close(); err != nil {
+			log.Printf("Failed to close embedding cache: %v", err)
+		}
+	}
+}
+
+// mustEnv 返回环境变量配置，格式不正确时退出
+func mustEnv[T any](v T, err error) T {
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	return v
+}
+
+// mustLookupEnv 同 mustEnv，用于 envconfig.LookupInt 等返回是否设置的函数
+func mustLookupEnv[T any](v T, ok bool, err error) (T, bool) {
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	return v, ok
 }
@@ -74,11 +74,11 @@ func test() {
 
 	for _, query := range queries {
 		fmt.Printf("Query: %s\n", query)
-		answer, err := ragService.Query(ctx, query, 2)
+		result, err := ragService.Query(ctx, query, 2)
 		if err != nil {
 			log.Printf("Error: %v", err)
 			continue
 		}
-		fmt.Printf("Answer: %s\n\n", answer)
+		fmt.Printf("Answer: %s\n\n", result.Answer)
 	}
 }
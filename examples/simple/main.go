@@ -95,12 +95,16 @@ func main() {
 	fmt.Println("  3. 构建提示词...")
 	fmt.Println("  4. 调用 LLM 生成回答...\n")
 
-	answer, err := ragService.Query(ctx, query, 2) // topK=2，找最相似的 2 个文档
+	result, err := ragService.Query(ctx, query, 2) // topK=2，找最相似的 2 个文档
 	if err != nil {
 		log.Fatalf("查询失败: %v", err)
 	}
 
-	fmt.Printf("系统回答: %s\n\n", answer)
+	fmt.Printf("系统回答: %s\n\n", result.Answer)
+	for _, source := range result.Sources {
+		fmt.Printf("  [%d] %s (score=%.3f)\n", source.Index, source.ID, source.Score)
+	}
+	fmt.Println()
 
 	// ============================================
 	// 总结：RAG 的核心思想
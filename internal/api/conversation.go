@@ -0,0 +1,178 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goRag/internal/session"
+)
+
+const (
+	defaultConversationPageSize = 20  // 会话列表默认每页条数
+	maxConversationPageSize     = 100 // 会话列表每页最多条数
+)
+
+// ConversationItem 会话摘要
+type ConversationItem struct {
+	ID           string    `json:"id"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ConversationListResponse 会话列表的一页
+type ConversationListResponse struct {
+	Conversations []ConversationItem `json:"conversations"`
+	Total         int                `json:"total"` // 会话总数
+	Offset        int                `json:"offset"`
+	Limit         int                `json:"limit"`
+}
+
+// ConversationResponse 会话详情
+type ConversationResponse struct {
+	ConversationItem
+	Messages []MessageItem `json:"messages"`
+}
+
+// MessageItem 会话中的一条消息
+type MessageItem struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatResponse 会话中一轮问答的响应
+type ChatResponse struct {
+	QueryResponse
+	ConversationID  string `json:"conversation_id"`
+	StandaloneQuery string `json:"standalone_query"`
+}
+
+// handleCreateConversation 创建会话
+func (s *Server) handleCreateConversation(c *gin.Context) {
+	conversation, err := s.ragService.CreateConversation(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newConversationItem(conversation))
+}
+
+// handleListConversations 分页列出会话，?offset=0&limit=20
+func (s *Server) handleListConversations(c *gin.Context) {
+	offset, ok := queryInt(c, "offset", 0)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(c, "limit", defaultConversationPageSize)
+	if !ok {
+		return
+	}
+	if limit == 0 || limit > maxConversationPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("limit must be between 1 and %d", maxConversationPageSize)})
+		return
+	}
+
+	conversations, total, err := s.ragService.ListConversations(c.Request.Context(), offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	items := make([]ConversationItem, len(conversations))
+	for i, conversation := range conversations {
+		items[i] = newConversationItem(conversation)
+	}
+	c.JSON(http.StatusOK, ConversationListResponse{
+		Conversations: items,
+		Total:         total,
+		Offset:        offset,
+		Limit:         limit,
+	})
+}
+
+// handleGetConversation 获取会话及历史消息
+func (s *Server) handleGetConversation(c *gin.Context) {
+	conversation, err := s.ragService.GetConversation(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeConversationError(c, err)
+		return
+	}
+
+	resp := ConversationResponse{
+		ConversationItem: newConversationItem(conversation),
+		Messages:         make([]MessageItem, len(conversation.Messages)),
+	}
+	for i, msg := range conversation.Messages {
+		resp.Messages[i] = MessageItem{Role: msg.Role, Content: msg.Content}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleChat 在会话中继续提问，请求体与 /query 相同
+func (s *Server) handleChat(c *gin.Context) {
+	req, opts, ok := s.bindQueryRequest(c)
+	if !ok {
+		return
+	}
+
+	result, err := s.ragService.Chat(c.Request.Context(), c.Param("id"), req.Query, opts)
+	if err != nil {
+		writeConversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ChatResponse{
+		QueryResponse:   newQueryResponse(result.QueryResult),
+		ConversationID:  result.ConversationID,
+		StandaloneQuery: result.StandaloneQuery,
+	})
+}
+
+// handleDeleteConversation 删除会话
+func (s *Server) handleDeleteConversation(c *gin.Context) {
+	if err := s.ragService.DeleteConversation(c.Request.Context(), c.Param("id")); err != nil {
+		writeConversationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// writeConversationError 会话不存在返回 404，其他错误返回 500
+func writeConversationError(c *gin.Context, err error) {
+	if errors.Is(err, session.ErrNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+}
+
+// queryInt 读取非负整数查询参数，未设置时返回 def，格式不正确时返回 400
+func queryInt(c *gin.Context, name string, def int) (int, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("%s must be a non-negative integer", name)})
+		return 0, false
+	}
+	return n, true
+}
+
+// newConversationItem 把会话转换为摘要结构
+func newConversationItem(conversation *session.Session) ConversationItem {
+	return ConversationItem{
+		ID:           conversation.ID,
+		MessageCount: len(conversation.Messages),
+		CreatedAt:    conversation.CreatedAt,
+		UpdatedAt:    conversation.UpdatedAt,
+	}
+}
@@ -8,7 +8,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"goRag/internal/llm"
 	"goRag/internal/rag"
+	"goRag/internal/resilience"
 	"goRag/internal/retriever"
 )
 
@@ -17,10 +19,21 @@ type Server struct {
 	ragService *rag.RAGService
 	router     *gin.Engine
 	httpServer *http.Server
+	breakers   []*resilience.Breaker
+}
+
+// ServerOption API 服务器选项
+type ServerOption func(*Server)
+
+// WithBreakers 在健康检查中报告后端服务（LLM、嵌入模型）熔断器的状态
+func WithBreakers(breakers ...*resilience.Breaker) ServerOption {
+	return func(s *Server) {
+		s.breakers = append(s.breakers, breakers...)
+	}
 }
 
 // NewServer 创建新的 API 服务器
-func NewServer(ragService *rag.RAGService) *Server {
+func NewServer(ragService *rag.RAGService, opts ...ServerOption) *Server {
 	// 设置 Gin 模式
 	gin.SetMode(gin.ReleaseMode)
 
@@ -38,6 +51,9 @@ func NewServer(ragService *rag.RAGService) *Server {
 			Handler: router,
 		},
 	}
+	for _, opt := range opts {
+		opt(server)
+	}
 
 	// 注册路由
 	server.registerRoutes()
@@ -50,8 +66,14 @@ func (s *Server) registerRoutes() {
 	api := s.router.Group("/api/v1")
 	{
 		api.POST("/query", s.handleQuery)
+		api.POST("/query/stream", s.handleQueryStream)
 		api.POST("/documents", s.handleAddDocuments)
 		api.DELETE("/documents", s.handleDeleteDocument)
+		api.POST("/conversations", s.handleCreateConversation)
+		api.GET("/conversations", s.handleListConversations)
+		api.GET("/conversations/:id", s.handleGetConversation)
+		api.POST("/conversations/:id/messages", s.handleChat)
+		api.DELETE("/conversations/:id", s.handleDeleteConversation)
 		api.GET("/health", s.handleHealth)
 	}
 }
@@ -72,13 +94,56 @@ func (s *Server) Start(ctx context.Context) error {
 
 // QueryRequest 查询请求
 type QueryRequest struct {
-	Query string `json:"query" binding:"required"`
-	TopK  int    `json:"top_k,omitempty"`
+	Query     string            `json:"query" binding:"required"`
+	TopK      int               `json:"top_k,omitempty"`
+	Filter    *retriever.Filter `json:"filter,omitempty"`
+	MMRLambda *float64          `json:"mmr_lambda,omitempty"`
+
+	// ExpandWindow 文档切分时把命中分块扩展为前后各 n 个相邻分块，-1 表示整篇原文档
+	ExpandWindow *int `json:"expand_window,omitempty"`
+
+	// 生成参数，均可选；temperature 设为 0 并指定 seed 可获得可复现的回答
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	JSONMode    bool     `json:"json_mode,omitempty"` // 要求模型输出 JSON 对象，提示词中需要说明 JSON 格式
+
+	// Template 提示模板名称，空表示默认模板
+	Template string `json:"template,omitempty"`
 }
 
 // QueryResponse 查询响应
 type QueryResponse struct {
-	Answer string `json:"answer"`
+	Answer    string         `json:"answer"`
+	Sources   []SourceItem   `json:"sources"`
+	Citations []CitationItem `json:"citations"`
+	Context   *ContextItem   `json:"context,omitempty"`
+}
+
+// ContextItem 上下文打包情况
+type ContextItem struct {
+	BudgetTokens int      `json:"budget_tokens"`
+	UsedTokens   int      `json:"used_tokens"`
+	Truncated    []string `json:"truncated"`
+	Dropped      []string `json:"dropped"`
+}
+
+// SourceItem 回答参考的文档
+type SourceItem struct {
+	Index    int                    `json:"index"`
+	ID       string                 `json:"id"`
+	Score    float64                `json:"score"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Snippet  string                 `json:"snippet"`
+}
+
+// CitationItem 回答中的引用
+type CitationItem struct {
+	Marker     int    `json:"marker"`
+	DocumentID string `json:"document_id"`
 }
 
 // DocumentRequest 文档请求
@@ -100,23 +165,181 @@ type ErrorResponse struct {
 
 // handleQuery 处理查询请求
 func (s *Server) handleQuery(c *gin.Context) {
+	req, opts, ok := s.bindQueryRequest(c)
+	if !ok {
+		return
+	}
+
+	result, err := s.ragService.QueryWithOptions(c.Request.Context(), req.Query, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newQueryResponse(result))
+}
+
+// bindQueryRequest 解析并校验查询请求，失败时已写入 400 响应
+func (s *Server) bindQueryRequest(c *gin.Context) (QueryRequest, rag.QueryOptions, bool) {
 	var req QueryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
-		return
+		return req, rag.QueryOptions{}, false
 	}
 
+	if req.TopK < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "top_k must be non-negative"})
+		return req, rag.QueryOptions{}, false
+	}
 	if req.TopK == 0 {
 		req.TopK = 5
 	}
+	if err := req.Filter.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid filter: " + err.Error()})
+		return req, rag.QueryOptions{}, false
+	}
+	if req.MMRLambda != nil && (*req.MMRLambda < 0 || *req.MMRLambda > 1) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "mmr_lambda must be between 0 and 1"})
+		return req, rag.QueryOptions{}, false
+	}
 
-	answer, err := s.ragService.Query(c.Request.Context(), req.Query, req.TopK)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	if req.ExpandWindow != nil && *req.ExpandWindow < retriever.ExpandWhole {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "expand_window must be -1 (whole document) or non-negative"})
+		return req, rag.QueryOptions{}, false
+	}
+	if !s.ragService.HasPromptTemplate(req.Template) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "unknown prompt template: " + req.Template})
+		return req, rag.QueryOptions{}, false
+	}
+
+	generation := llm.GenerateOptions{
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+		Seed:        req.Seed,
+		Model:       req.Model,
+		JSONMode:    req.JSONMode,
+	}
+	if err := generation.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid generation options: " + err.Error()})
+		return req, rag.QueryOptions{}, false
+	}
+
+	return req, rag.QueryOptions{
+		TopK:       req.TopK,
+		Filter:     req.Filter,
+		MMRLambda:  req.MMRLambda,
+		Generation: generation,
+		Template:   req.Template,
+
+		ExpandWindow: req.ExpandWindow,
+	}, true
+}
+
+// StreamRetrievalEvent SSE retrieval 事件
+type StreamRetrievalEvent struct {
+	Sources []SourceItem `json:"sources"`
+}
+
+// StreamDeltaEvent SSE delta 事件
+type StreamDeltaEvent struct {
+	Content string `json:"content"`
+}
+
+// StreamDoneEvent SSE done 事件
+type StreamDoneEvent struct {
+	QueryResponse
+	Timing TimingItem `json:"timing"`
+}
+
+// TimingItem 各阶段耗时（毫秒）
+type TimingItem struct {
+	RetrievalMs  int64 `json:"retrieval_ms"`
+	GenerationMs int64 `json:"generation_ms"`
+	TotalMs      int64 `json:"total_ms"`
+}
+
+// handleQueryStream 处理流式查询请求（Server-Sent Events）
+// 事件顺序：retrieval -> delta... -> done；出错时发送 error 事件。
+// 客户端断开后请求上下文被取消，LLM 生成随之停止。
+func (s *Server) handleQueryStream(c *gin.Context) {
+	req, opts, ok := s.bindQueryRequest(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, QueryResponse{Answer: answer})
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ctx := c.Request.Context()
+	emit := func(event rag.StreamEvent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch event.Type {
+		case rag.EventRetrieval:
+			c.SSEvent(event.Type, StreamRetrievalEvent{Sources: newQueryResponse(&rag.QueryResult{Sources: event.Sources}).Sources})
+		case rag.EventDelta:
+			c.SSEvent(event.Type, StreamDeltaEvent{Content: event.Delta})
+		case rag.EventDone:
+			c.SSEvent(event.Type, StreamDoneEvent{
+				QueryResponse: newQueryResponse(event.Result),
+				Timing: TimingItem{
+					RetrievalMs:  event.Timing.Retrieval.Milliseconds(),
+					GenerationMs: event.Timing.Generation.Milliseconds(),
+					TotalMs:      event.Timing.Total.Milliseconds(),
+				},
+			})
+		}
+		c.Writer.Flush()
+		return ctx.Err()
+	}
+
+	if err := s.ragService.QueryStream(ctx, req.Query, opts, emit); err != nil {
+		if ctx.Err() != nil {
+			log.Printf("query stream stopped: client disconnected")
+			return
+		}
+		c.SSEvent("error", ErrorResponse{Error: err.Error()})
+		c.Writer.Flush()
+	}
+}
+
+// newQueryResponse 把查询结果转换为响应结构
+func newQueryResponse(result *rag.QueryResult) QueryResponse {
+	resp := QueryResponse{
+		Answer:    result.Answer,
+		Sources:   make([]SourceItem, len(result.Sources)),
+		Citations: make([]CitationItem, len(result.Citations)),
+	}
+	for i, source := range result.Sources {
+		resp.Sources[i] = SourceItem{
+			Index:    source.Index,
+			ID:       source.ID,
+			Score:    source.Score,
+			Metadata: source.Metadata,
+			Snippet:  source.Snippet,
+		}
+	}
+	if result.Packing != nil {
+		resp.Context = &ContextItem{
+			BudgetTokens: result.Packing.Budget,
+			UsedTokens:   result.Packing.Used,
+			Truncated:    result.Packing.Truncated,
+			Dropped:      result.Packing.Dropped,
+		}
+	}
+	for i, citation := range result.Citations {
+		resp.Citations[i] = CitationItem{
+			Marker:     citation.Marker,
+			DocumentID: citation.DocumentID,
+		}
+	}
+	return resp
 }
 
 // handleAddDocuments 处理添加文档请求
@@ -160,7 +383,22 @@ func (s *Server) handleDeleteDocument(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// HealthResponse 健康检查响应
+type HealthResponse struct {
+	Status   string              `json:"status"` // healthy，或有后端熔断时为 degraded
+	Breakers []resilience.Status `json:"breakers,omitempty"`
+}
+
 // handleHealth 处理健康检查
+// 后端熔断时服务进程本身仍然正常，因此仍返回 200，由 status 区分
 func (s *Server) handleHealth(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+	resp := HealthResponse{Status: "healthy"}
+	for _, breaker := range s.breakers {
+		status := breaker.Status()
+		if status.State != resilience.StateClosed {
+			resp.Status = "degraded"
+		}
+		resp.Breakers = append(resp.Breakers, status)
+	}
+	c.JSON(http.StatusOK, resp)
 }
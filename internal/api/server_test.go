@@ -0,0 +1,302 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goRag/internal/embedding"
+	"goRag/internal/llm"
+	"goRag/internal/rag"
+	"goRag/internal/retriever"
+)
+
+// newTestServer 创建使用 SimpleEmbedder、内存检索器和指定 LLM 的 API 服务器
+func newTestServer(t *testing.T, model llm.LLM) *Server {
+	t.Helper()
+	embedder := embedding.NewSimpleEmbedder(64)
+	memory, err := retriever.NewMemoryRetriever(embedder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ragService := rag.NewRAGService(
+		embedding.NewService(embedder),
+		retriever.NewService(memory),
+		llm.NewService(model),
+	)
+	return NewServer(ragService)
+}
+
+func TestBindQueryRequestGenerationOptions(t *testing.T) {
+	temperature, topP, seed := 0.0, 0.9, 42
+	tests := []struct {
+		name string
+		body string
+		want llm.GenerateOptions
+	}{
+		{
+			name: "defaults",
+			body: `{"query": "q"}`,
+			want: llm.GenerateOptions{},
+		},
+		{
+			name: "all fields",
+			body: `{"query": "q", "temperature": 0, "top_p": 0.9, "max_tokens": 64, "stop": ["\n\n"],
+				"seed": 42, "model": "qwen2.5:7b", "json_mode": true}`,
+			want: llm.GenerateOptions{
+				Temperature: &temperature,
+				TopP:        &topP,
+				MaxTokens:   64,
+				Stop:        []string{"\n\n"},
+				Seed:        &seed,
+				Model:       "qwen2.5:7b",
+				JSONMode:    true,
+			},
+		},
+		{
+			name: "json mode off",
+			body: `{"query": "q", "json_mode": false}`,
+			want: llm.GenerateOptions{},
+		},
+	}
+	server := newTestServer(t, llm.NewMockLLM())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			_, opts, ok := server.bindQueryRequest(c)
+			if !ok {
+				t.Fatalf("bindQueryRequest rejected %s: %s", tt.body, w.Body.String())
+			}
+			if !reflect.DeepEqual(opts.Generation, tt.want) {
+				t.Errorf("Generation = %+v, want %+v", opts.Generation, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindQueryRequestRejectsInvalidGeneration(t *testing.T) {
+	server := newTestServer(t, llm.NewMockLLM())
+	for _, body := range []string{
+		`{"query": "q", "temperature": 3}`,
+		`{"query": "q", "top_p": 0}`,
+		`{"query": "q", "max_tokens": -1}`,
+		`{"query": "q", "json_mode": "yes"}`,
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		if _, _, ok := server.bindQueryRequest(c); ok || w.Code != http.StatusBadRequest {
+			t.Errorf("bindQueryRequest(%s) ok = %v, status = %d, want 400", body, ok, w.Code)
+		}
+	}
+}
+
+// sseEvent 一个 Server-Sent Event
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSE 逐个读取事件并交给 handle，handle 返回 false 时停止读取
+func readSSE(t *testing.T, body io.Reader, handle func(sseEvent) bool) {
+	t.Helper()
+	scanner := bufio.NewScanner(body)
+	var event sseEvent
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			event.data += strings.TrimPrefix(line, "data:")
+		case line == "" && event.name != "":
+			if !handle(event) {
+				return
+			}
+			event = sseEvent{}
+		}
+	}
+}
+
+// streamLLM 流式生成由 stream 决定的测试 LLM
+type streamLLM struct {
+	stream func(ctx context.Context, callback func(string) error) error
+}
+
+func (l *streamLLM) Generate(ctx context.Context, messages []llm.Message, opts llm.GenerateOptions) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
+func (l *streamLLM) GenerateStream(ctx context.Context, messages []llm.Message, opts llm.GenerateOptions, callback func(string) error) error {
+	return l.stream(ctx, callback)
+}
+
+func (l *streamLLM) GetModelName() string {
+	return "stream-test"
+}
+
+// startStreamServer 启动带一篇文档的测试服务器
+func startStreamServer(t *testing.T, model llm.LLM) *httptest.Server {
+	t.Helper()
+	server := newTestServer(t, model)
+	err := server.ragService.AddDocuments(context.Background(), []retriever.Document{
+		{ID: "go", Content: "Go 是 Google 开发的编程语言"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.router)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func postStream(t *testing.T, ctx context.Context, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/api/v1/query/stream", strings.NewReader(`{"query": "Go 是什么"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	return resp
+}
+
+func TestQueryStreamEventOrder(t *testing.T) {
+	ts := startStreamServer(t, llm.NewMockLLM())
+	resp := postStream(t, context.Background(), ts.URL)
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	var names []string
+	var answer strings.Builder
+	var done StreamDoneEvent
+	readSSE(t, resp.Body, func(event sseEvent) bool {
+		names = append(names, event.name)
+		switch event.name {
+		case "retrieval":
+			var retrieval StreamRetrievalEvent
+			if err := json.Unmarshal([]byte(event.data), &retrieval); err != nil {
+				t.Fatal(err)
+			}
+			if len(retrieval.Sources) != 1 || retrieval.Sources[0].ID != "go" {
+				t.Errorf("retrieval sources = %+v, want the go document", retrieval.Sources)
+			}
+		case "delta":
+			var delta StreamDeltaEvent
+			if err := json.Unmarshal([]byte(event.data), &delta); err != nil {
+				t.Fatal(err)
+			}
+			answer.WriteString(delta.Content)
+		case "done":
+			if err := json.Unmarshal([]byte(event.data), &done); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return true
+	})
+
+	if len(names) < 3 || names[0] != "retrieval" || names[len(names)-1] != "done" {
+		t.Fatalf("events = %v, want retrieval, delta..., done", names)
+	}
+	for _, name := range names[1 : len(names)-1] {
+		if name != "delta" {
+			t.Errorf("events = %v, want only delta between retrieval and done", names)
+			break
+		}
+	}
+	if strings.TrimSpace(done.Answer) != strings.TrimSpace(answer.String()) {
+		t.Errorf("done answer = %q, want concatenated deltas %q", done.Answer, answer.String())
+	}
+}
+
+func TestQueryStreamErrorEvent(t *testing.T) {
+	ts := startStreamServer(t, &streamLLM{stream: func(ctx context.Context, callback func(string) error) error {
+		if err := callback("partial "); err != nil {
+			return err
+		}
+		return fmt.Errorf("model overloaded")
+	}})
+	resp := postStream(t, context.Background(), ts.URL)
+	defer resp.Body.Close()
+
+	var events []sseEvent
+	readSSE(t, resp.Body, func(event sseEvent) bool {
+		events = append(events, event)
+		return true
+	})
+
+	var names []string
+	for _, event := range events {
+		names = append(names, event.name)
+	}
+	if !slices.Equal(names, []string{"retrieval", "delta", "error"}) {
+		t.Fatalf("events = %v, want [retrieval delta error]", names)
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal([]byte(events[2].data), &errResp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(errResp.Error, "model overloaded") {
+		t.Errorf("error event = %q, want it to mention the LLM error", errResp.Error)
+	}
+}
+
+func TestQueryStreamCancelsOnDisconnect(t *testing.T) {
+	canceled := make(chan struct{})
+	ts := startStreamServer(t, &streamLLM{stream: func(ctx context.Context, callback func(string) error) error {
+		if err := callback("first "); err != nil {
+			return err
+		}
+		// 模拟仍在生成的模型：直到请求被取消
+		select {
+		case <-ctx.Done():
+			close(canceled)
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("generation was not canceled")
+		}
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp := postStream(t, ctx, ts.URL)
+	defer resp.Body.Close()
+
+	// 收到第一个 delta 后断开连接
+	readSSE(t, resp.Body, func(event sseEvent) bool {
+		return event.name != "delta"
+	})
+	cancel()
+
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("LLM generation was not canceled after the client disconnected")
+	}
+}
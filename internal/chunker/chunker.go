@@ -0,0 +1,207 @@
+package chunker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 分块写入元数据的键
+const (
+	MetadataParentID   = "parent_id"   // 原文档 ID
+	MetadataChunkIndex = "chunk_index" // 分块序号（从 0 开始）
+	MetadataStart      = "start"       // 分块在原文中的起始字节偏移
+	MetadataEnd        = "end"         // 分块在原文中的结束字节偏移（不含）
+	MetadataSection    = "section"     // markdown 标题路径，如 "安装 > 依赖"
+)
+
+// Segment 切分出的一段文本
+type Segment struct {
+	Start    int                    // 在原文中的起始字节偏移
+	End      int                    // 在原文中的结束字节偏移（不含）
+	Metadata map[string]interface{} // 切分器附加的元数据（如 markdown 标题），可以为 nil
+}
+
+// Splitter 文本切分器
+// 返回的片段按原文顺序排列，相邻片段可以重叠（overlap），片段内容为 text[Start:End]。
+type Splitter interface {
+	// Split 切分文本
+	Split(text string) []Segment
+}
+
+// Chunk 文档切分后的一个分块
+type Chunk struct {
+	ID       string                 // 分块 ID，格式为 "<原文档 ID>#<序号>"
+	ParentID string                 // 原文档 ID
+	Index    int                    // 分块序号（从 0 开始）
+	Content  string                 // 分块内容
+	Start    int                    // 在原文中的起始字节偏移
+	End      int                    // 在原文中的结束字节偏移（不含）
+	Metadata map[string]interface{} // 继承原文档的元数据，并附加 parent_id、chunk_index、start、end 等
+}
+
+// ChunkID 生成分块 ID
+func ChunkID(parentID string, index int) string {
+	return fmt.Sprintf("%s#%d", parentID, index)
+}
+
+// Split 用切分器把文档切成分块
+func Split(splitter Splitter, parentID, content string, metadata map[string]interface{}) []Chunk {
+	segments := splitter.Split(content)
+	chunks := make([]Chunk, 0, len(segments))
+	for _, seg := range segments {
+		index := len(chunks)
+
+		chunkMetadata := make(map[string]interface{}, len(metadata)+len(seg.Metadata)+4)
+		for k, v := range metadata {
+			chunkMetadata[k] = v
+		}
+		for k, v := range seg.Metadata {
+			chunkMetadata[k] = v
+		}
+		chunkMetadata[MetadataParentID] = parentID
+		chunkMetadata[MetadataChunkIndex] = index
+		chunkMetadata[MetadataStart] = seg.Start
+		chunkMetadata[MetadataEnd] = seg.End
+
+		chunks = append(chunks, Chunk{
+			ID:       ChunkID(parentID, index),
+			ParentID: parentID,
+			Index:    index,
+			Content:  content[seg.Start:seg.End],
+			Start:    seg.Start,
+			End:      seg.End,
+			Metadata: chunkMetadata,
+		})
+	}
+	return chunks
+}
+
+// span 原文中的一个区间 [start, end)
+type span struct {
+	start, end int
+}
+
+// measureFunc 计算一段文本的长度（字符数或 token 数）
+type measureFunc func(text string) int
+
+// runeCount 按字符计算长度
+func runeCount(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+// merge 把连续的小片段合并成不超过 size 的分块，相邻分块重叠不超过 overlap
+// 单个片段超过 size 时单独成块（由调用方保证片段已尽量切小）。
+func merge(text string, pieces []span, size, overlap int, measure measureFunc) []Segment {
+	var segments []Segment
+	var window []span
+	var lengths []int
+	total := 0
+
+	emit := func() {
+		if seg, ok := trimmed(text, window[0].start, window[len(window)-1].end); ok {
+			// 与上一个分块完全相同时跳过（只剩重叠部分的情况）
+			if n := len(segments); n == 0 || segments[n-1].Start != seg.Start || segments[n-1].End != seg.End {
+				segments = append(segments, seg)
+			}
+		}
+	}
+
+	for _, p := range pieces {
+		n := measure(text[p.start:p.end])
+		if len(window) > 0 && total+n > size {
+			emit()
+			// 保留末尾不超过 overlap 的片段作为下一块的开头
+			for len(window) > 0 && (total > overlap || total+n > size) {
+				total -= lengths[0]
+				window, lengths = window[1:], lengths[1:]
+			}
+		}
+		window = append(window, p)
+		lengths = append(lengths, n)
+		total += n
+	}
+	if len(window) > 0 {
+		emit()
+	}
+	return segments
+}
+
+// trimmed 去掉区间首尾的空白，区间为空时返回 false
+func trimmed(text string, start, end int) (Segment, bool) {
+	content := text[start:end]
+	trimmedLeft := strings.TrimLeftFunc(content, unicode.IsSpace)
+	start += len(content) - len(trimmedLeft)
+	end = start + len(strings.TrimRightFunc(trimmedLeft, unicode.IsSpace))
+	if start >= end {
+		return Segment{}, false
+	}
+	return Segment{Start: start, End: end}, true
+}
+
+// validateSize 校验分块大小和重叠
+func validateSize(size, overlap int) error {
+	if size <= 0 {
+		return fmt.Errorf("chunk size must be positive, got %d", size)
+	}
+	if overlap < 0 || overlap >= size {
+		return fmt.Errorf("chunk overlap must be in [0, %d), got %d", size, overlap)
+	}
+	return nil
+}
+
+// Parse 根据配置字符串创建切分器，格式为 "名称[:大小[:重叠]]"：
+//
+//	"recursive:800:100"  // 递归字符切分，800 字符一块，重叠 100 字符
+//	"sentence:500:50"    // 按句子切分
+//	"token:256:32"       // 按 token 切分（启发式计数）
+//	"markdown:800:100"   // 先按 markdown 标题切分章节，超长章节再递归字符切分；省略大小时每个章节一块
+//
+// 只指定大小时重叠取默认值和大小的 1/8 中较小的一个，如 "sentence:50" 的重叠为 6。
+func Parse(spec string) (Splitter, error) {
+	fields := strings.Split(strings.TrimSpace(spec), ":")
+	params := make([]int, len(fields)-1)
+	for i, field := range fields[1:] {
+		v, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %q for chunker %q", field, fields[0])
+		}
+		params[i] = v
+	}
+	param := func(i int, def int) int {
+		if i < len(params) {
+			return params[i]
+		}
+		return def
+	}
+	// overlap 省略时不超过大小的 1/8，避免只指定较小的大小时默认重叠反而不合法
+	overlap := func(size, def int) int {
+		return param(1, min(def, size/8))
+	}
+
+	switch fields[0] {
+	case "recursive":
+		size := param(0, 800)
+		return NewRecursiveSplitter(size, overlap(size, 100))
+	case "sentence":
+		size := param(0, 500)
+		return NewSentenceSplitter(size, overlap(size, 50))
+	case "token":
+		size := param(0, 256)
+		return NewTokenSplitter(nil, size, overlap(size, 32))
+	case "markdown":
+		if len(params) == 0 {
+			return NewMarkdownSplitter(nil), nil
+		}
+		size := param(0, 800)
+		inner, err := NewRecursiveSplitter(size, overlap(size, 100))
+		if err != nil {
+			return nil, err
+		}
+		return NewMarkdownSplitter(inner), nil
+	default:
+		return nil, fmt.Errorf("unknown chunker %q", fields[0])
+	}
+}
@@ -0,0 +1,257 @@
+package chunker
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// contents 返回片段对应的文本
+func contents(text string, segments []Segment) []string {
+	out := make([]string, len(segments))
+	for i, seg := range segments {
+		out[i] = text[seg.Start:seg.End]
+	}
+	return out
+}
+
+// checkSegments 校验片段的通用约束：偏移有效、按原文顺序、首尾无空白、长度不超过 size（单个不可再分的片段除外）
+func checkSegments(t *testing.T, text string, segments []Segment, size int) {
+	t.Helper()
+	prevStart := -1
+	for i, seg := range segments {
+		if seg.Start < 0 || seg.End > len(text) || seg.Start >= seg.End {
+			t.Fatalf("segment %d has invalid range [%d, %d)", i, seg.Start, seg.End)
+		}
+		if seg.Start <= prevStart {
+			t.Errorf("segment %d starts at %d, not after previous start %d", i, seg.Start, prevStart)
+		}
+		prevStart = seg.Start
+		content := text[seg.Start:seg.End]
+		if strings.TrimSpace(content) != content {
+			t.Errorf("segment %d %q is not trimmed", i, content)
+		}
+		if size > 0 && utf8.RuneCountInString(content) > size {
+			t.Errorf("segment %d %q exceeds size %d", i, content, size)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	text := "aaaa bbbb cccc dddd "
+	pieces := []span{{0, 5}, {5, 10}, {10, 15}, {15, 20}}
+
+	tests := []struct {
+		name     string
+		size     int
+		overlap  int
+		expected []string
+	}{
+		{"fits in one", 100, 0, []string{"aaaa bbbb cccc dddd"}},
+		{"no overlap", 10, 0, []string{"aaaa bbbb", "cccc dddd"}},
+		{"overlap one piece", 10, 5, []string{"aaaa bbbb", "bbbb cccc", "cccc dddd"}},
+		{"piece larger than size", 3, 0, []string{"aaaa", "bbbb", "cccc", "dddd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contents(text, merge(text, pieces, tt.size, tt.overlap, runeCount))
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("merge = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeSkipsBlankAndDuplicate(t *testing.T) {
+	text := "aaaa    "
+	pieces := []span{{0, 4}, {4, 8}}
+	got := contents(text, merge(text, pieces, 4, 0, runeCount))
+	if len(got) != 1 || got[0] != "aaaa" {
+		t.Errorf("merge = %q, want [\"aaaa\"]", got)
+	}
+}
+
+func TestRecursiveSplitter(t *testing.T) {
+	text := "第一段第一句。第一段第二句。\n\n第二段很短。\n\nThe third paragraph is written in English. It has two sentences."
+
+	splitter, err := NewRecursiveSplitter(20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := splitter.Split(text)
+	checkSegments(t, text, segments, 20)
+
+	got := contents(text, segments)
+	// 先按段落切分，超长段落再按句子、空格切分，最后贪心合并相邻的小片段
+	want := []string{
+		"第一段第一句。第一段第二句。",
+		"第二段很短。\n\nThe third",
+		"paragraph is",
+		"written in English.",
+		"It has two",
+		"sentences.",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Split = %q, want %q", got, want)
+	}
+}
+
+func TestRecursiveSplitterOverlap(t *testing.T) {
+	text := "one two three four five six seven eight nine ten"
+	splitter, err := NewRecursiveSplitter(15, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := splitter.Split(text)
+	checkSegments(t, text, segments, 15)
+	if len(segments) < 2 {
+		t.Fatalf("expected several chunks, got %q", contents(text, segments))
+	}
+	for i := 1; i < len(segments); i++ {
+		if segments[i].Start >= segments[i-1].End {
+			t.Errorf("chunk %d [%d,%d) does not overlap previous [%d,%d)",
+				i, segments[i].Start, segments[i].End, segments[i-1].Start, segments[i-1].End)
+		}
+	}
+	// 所有内容都被覆盖
+	if segments[0].Start != 0 || segments[len(segments)-1].End != len(text) {
+		t.Errorf("chunks do not cover the whole text: %q", contents(text, segments))
+	}
+}
+
+func TestRecursiveSplitterFallsBackToCharacters(t *testing.T) {
+	text := strings.Repeat("字", 25)
+	splitter, err := NewRecursiveSplitter(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := splitter.Split(text)
+	checkSegments(t, text, segments, 10)
+	if len(segments) != 3 {
+		t.Errorf("got %d chunks, want 3: %q", len(segments), contents(text, segments))
+	}
+}
+
+func TestSentenceSplitter(t *testing.T) {
+	text := "今天天气很好。我们去公园散步吧！好的？Sure. Let's go."
+
+	tests := []struct {
+		name     string
+		size     int
+		overlap  int
+		expected []string
+	}{
+		{"no overlap", 12, 0, []string{"今天天气很好。", "我们去公园散步吧！好的？", "Sure.", "Let's go."}},
+		// 重叠的是完整的句子
+		{"overlap", 14, 7, []string{"今天天气很好。", "我们去公园散步吧！好的？", "好的？Sure.", "Let's go."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splitter, err := NewSentenceSplitter(tt.size, tt.overlap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			segments := splitter.Split(text)
+			checkSegments(t, text, segments, tt.size)
+			if got := contents(text, segments); strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Split = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMarkdownSplitter(t *testing.T) {
+	text := "前言\n\n# 安装\n\n安装说明。\n\n## 依赖\n\n```\n# 不是标题\n```\n\n# 使用\n\n用法。\n"
+
+	segments := NewMarkdownSplitter(nil).Split(text)
+	checkSegments(t, text, segments, 0)
+
+	type section struct {
+		content string
+		section interface{}
+	}
+	want := []section{
+		{"前言", nil},
+		{"# 安装\n\n安装说明。", "安装"},
+		{"## 依赖\n\n```\n# 不是标题\n```", "安装 > 依赖"},
+		{"# 使用\n\n用法。", "使用"},
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("got %d sections %q, want %d", len(segments), contents(text, segments), len(want))
+	}
+	for i, seg := range segments {
+		if got := text[seg.Start:seg.End]; got != want[i].content {
+			t.Errorf("section %d = %q, want %q", i, got, want[i].content)
+		}
+		if got := seg.Metadata[MetadataSection]; got != want[i].section {
+			t.Errorf("section %d metadata = %v, want %v", i, got, want[i].section)
+		}
+	}
+}
+
+func TestMarkdownSplitterWithInner(t *testing.T) {
+	text := "# 标题\n\n" + strings.Repeat("很长的段落。", 10)
+	inner, err := NewRecursiveSplitter(20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := NewMarkdownSplitter(inner).Split(text)
+	checkSegments(t, text, segments, 20)
+	if len(segments) < 2 {
+		t.Fatalf("expected the long section to be split, got %q", contents(text, segments))
+	}
+	for i, seg := range segments {
+		if seg.Metadata[MetadataSection] != "标题" {
+			t.Errorf("chunk %d section = %v, want 标题", i, seg.Metadata[MetadataSection])
+		}
+	}
+}
+
+func TestSplitMetadataAndOffsets(t *testing.T) {
+	text := "alpha beta. gamma delta."
+	splitter, err := NewSentenceSplitter(12, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := Split(splitter, "doc", text, map[string]interface{}{"source": "test", MetadataParentID: "ignored"})
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.ID != ChunkID("doc", i) || chunk.Index != i || chunk.ParentID != "doc" {
+			t.Errorf("chunk %d identity = %s/%d/%s", i, chunk.ID, chunk.Index, chunk.ParentID)
+		}
+		if chunk.Content != text[chunk.Start:chunk.End] {
+			t.Errorf("chunk %d content %q does not match offsets [%d, %d)", i, chunk.Content, chunk.Start, chunk.End)
+		}
+		md := chunk.Metadata
+		if md["source"] != "test" || md[MetadataParentID] != "doc" || md[MetadataChunkIndex] != i ||
+			md[MetadataStart] != chunk.Start || md[MetadataEnd] != chunk.End {
+			t.Errorf("chunk %d metadata = %v", i, md)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"recursive", false},
+		{"recursive:100:10", false},
+		{"sentence:50", false},
+		{"recursive:4", false},
+		{"token:64:8", false},
+		{"markdown", false},
+		{"markdown:200:20", false},
+		{"recursive:10:10", true},
+		{"recursive:abc", true},
+		{"unknown", true},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package chunker
+
+import (
+	"strings"
+)
+
+// MarkdownSplitter 按 markdown 标题切分的切分器
+// 每个 ATX 标题（# 到 ######）开始一个新的章节，章节的标题路径（如 "安装 > 依赖"）写入元数据 section；
+// 代码块（``` 或 ~~~）中的 # 不视为标题。超过长度的章节交给 inner 切分器继续切分。
+type MarkdownSplitter struct {
+	inner Splitter
+}
+
+// NewMarkdownSplitter 创建 markdown 标题切分器，inner 为 nil 时每个章节作为一个分块
+func NewMarkdownSplitter(inner Splitter) *MarkdownSplitter {
+	return &MarkdownSplitter{
+		inner: inner,
+	}
+}
+
+// Split 切分文本
+func (m *MarkdownSplitter) Split(text string) []Segment {
+	var segments []Segment
+	for _, section := range splitSections(text) {
+		var metadata map[string]interface{}
+		if len(section.headers) > 0 {
+			metadata = map[string]interface{}{
+				MetadataSection: strings.Join(section.headers, " > "),
+			}
+		}
+
+		if m.inner == nil {
+			if seg, ok := trimmed(text, section.start, section.end); ok {
+				seg.Metadata = metadata
+				segments = append(segments, seg)
+			}
+			continue
+		}
+
+		for _, seg := range m.inner.Split(text[section.start:section.end]) {
+			seg.Start += section.start
+			seg.End += section.start
+			seg.Metadata = mergeMetadata(metadata, seg.Metadata)
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// markdownSection 一个章节：从标题行开始到下一个标题之前
+type markdownSection struct {
+	start, end int
+	headers    []string // 从一级到当前级的标题路径
+}
+
+// splitSections 按标题行切分章节
+func splitSections(text string) []markdownSection {
+	var sections []markdownSection
+	var headers []string // headers[i] 为 i+1 级标题，未出现的层级为空
+	start := 0
+	fence := ""
+
+	for offset := 0; offset < len(text); {
+		lineEnd := strings.IndexByte(text[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += offset + 1
+		}
+		line := strings.TrimSpace(text[offset:lineEnd])
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			fence = line[:3]
+		default:
+			if level, title, ok := parseHeader(line); ok {
+				if offset > start {
+					sections = append(sections, markdownSection{start: start, end: offset, headers: headerPath(headers)})
+				}
+				if len(headers) < level {
+					headers = append(headers, make([]string, level-len(headers))...)
+				}
+				headers = append(headers[:level-1], title)
+				start = offset
+			}
+		}
+		offset = lineEnd
+	}
+	if start < len(text) {
+		sections = append(sections, markdownSection{start: start, end: len(text), headers: headerPath(headers)})
+	}
+	return sections
+}
+
+// parseHeader 解析 ATX 标题行，返回级别和标题文本
+func parseHeader(line string) (int, string, bool) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, "", false
+	}
+	title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+	return level, title, true
+}
+
+// headerPath 返回非空标题组成的路径副本
+func headerPath(headers []string) []string {
+	path := make([]string, 0, len(headers))
+	for _, h := range headers {
+		if h != "" {
+			path = append(path, h)
+		}
+	}
+	return path
+}
+
+// mergeMetadata 合并两份元数据，后者优先
+func mergeMetadata(a, b map[string]interface{}) map[string]interface{} {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	merged := make(map[string]interface{}, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,98 @@
+package chunker
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultSeparators 递归切分默认使用的分隔符，按优先级从高到低：段落、换行、中英文句末标点、逗号、空格、单个字符
+var DefaultSeparators = []string{
+	"\n\n", "\n",
+	"。", "！", "？", ". ", "! ", "? ",
+	"；", "; ", "，", ", ",
+	" ", "",
+}
+
+// RecursiveSplitter 递归字符切分器
+// 先按优先级最高的分隔符切分，仍然超过 ChunkSize 的片段再用下一级分隔符切分，
+// 最后把小片段合并成不超过 ChunkSize 个字符的分块。分隔符保留在前一个片段末尾。
+type RecursiveSplitter struct {
+	chunkSize    int
+	chunkOverlap int
+	separators   []string
+}
+
+// NewRecursiveSplitter 创建递归字符切分器，chunkSize 和 chunkOverlap 按字符计
+// separators 为空时使用 DefaultSeparators
+func NewRecursiveSplitter(chunkSize, chunkOverlap int, separators ...string) (*RecursiveSplitter, error) {
+	if err := validateSize(chunkSize, chunkOverlap); err != nil {
+		return nil, err
+	}
+	if len(separators) == 0 {
+		separators = DefaultSeparators
+	}
+	return &RecursiveSplitter{
+		chunkSize:    chunkSize,
+		chunkOverlap: chunkOverlap,
+		separators:   separators,
+	}, nil
+}
+
+// Split 切分文本
+func (r *RecursiveSplitter) Split(text string) []Segment {
+	pieces := r.split(text, span{0, len(text)}, r.separators)
+	return merge(text, pieces, r.chunkSize, r.chunkOverlap, runeCount)
+}
+
+// split 用第一个出现在区间内的分隔符切分，超长的片段用剩余分隔符递归切分
+func (r *RecursiveSplitter) split(text string, s span, separators []string) []span {
+	if runeCount(text[s.start:s.end]) <= r.chunkSize {
+		return []span{s}
+	}
+
+	for i, sep := range separators {
+		if sep != "" && !strings.Contains(text[s.start:s.end], sep) {
+			continue
+		}
+
+		var pieces []span
+		for _, p := range splitKeepSeparator(text, s, sep) {
+			if runeCount(text[p.start:p.end]) > r.chunkSize && i+1 < len(separators) {
+				pieces = append(pieces, r.split(text, p, separators[i+1:])...)
+			} else {
+				pieces = append(pieces, p)
+			}
+		}
+		return pieces
+	}
+
+	return []span{s}
+}
+
+// splitKeepSeparator 按分隔符切分区间，分隔符归入前一段；sep 为空时按字符切分
+func splitKeepSeparator(text string, s span, sep string) []span {
+	var pieces []span
+	if sep == "" {
+		for i := s.start; i < s.end; {
+			_, size := utf8.DecodeRuneInString(text[i:s.end])
+			pieces = append(pieces, span{i, i + size})
+			i += size
+		}
+		return pieces
+	}
+
+	start := s.start
+	for start < s.end {
+		idx := strings.Index(text[start:s.end], sep)
+		if idx < 0 {
+			break
+		}
+		end := start + idx + len(sep)
+		pieces = append(pieces, span{start, end})
+		start = end
+	}
+	if start < s.end {
+		pieces = append(pieces, span{start, s.end})
+	}
+	return pieces
+}
@@ -0,0 +1,35 @@
+package chunker
+
+import (
+	"goRag/internal/tokenizer"
+)
+
+// SentenceSplitter 按句子切分的切分器
+// 在句末标点（。！？.!?；; 和换行）处断句，把连续的句子合并成不超过 ChunkSize 个字符的分块，
+// 分块之间重叠若干完整的句子（总长不超过 ChunkOverlap）。超长的单句单独成块。
+type SentenceSplitter struct {
+	chunkSize    int
+	chunkOverlap int
+}
+
+// NewSentenceSplitter 创建句子切分器，chunkSize 和 chunkOverlap 按字符计
+func NewSentenceSplitter(chunkSize, chunkOverlap int) (*SentenceSplitter, error) {
+	if err := validateSize(chunkSize, chunkOverlap); err != nil {
+		return nil, err
+	}
+	return &SentenceSplitter{
+		chunkSize:    chunkSize,
+		chunkOverlap: chunkOverlap,
+	}, nil
+}
+
+// Split 切分文本
+func (s *SentenceSplitter) Split(text string) []Segment {
+	var pieces []span
+	start := 0
+	for _, sentence := range tokenizer.SplitSentences(text) {
+		pieces = append(pieces, span{start, start + len(sentence)})
+		start += len(sentence)
+	}
+	return merge(text, pieces, s.chunkSize, s.chunkOverlap, runeCount)
+}
@@ -0,0 +1,75 @@
+package chunker
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"goRag/internal/tokenizer"
+)
+
+// TokenSplitter 按固定 token 数切分的切分器
+// 以中日韩单字、连续的非空白字符为最小单位（后面的空白归入该单位），
+// 用 tokenizer.Counter 计数，把单位合并成不超过 ChunkSize 个 token 的分块。
+type TokenSplitter struct {
+	counter      tokenizer.Counter
+	chunkSize    int
+	chunkOverlap int
+}
+
+// NewTokenSplitter 创建固定 token 切分器，counter 为 nil 时使用 HeuristicCounter
+func NewTokenSplitter(counter tokenizer.Counter, chunkSize, chunkOverlap int) (*TokenSplitter, error) {
+	if err := validateSize(chunkSize, chunkOverlap); err != nil {
+		return nil, err
+	}
+	if counter == nil {
+		counter = tokenizer.NewHeuristicCounter()
+	}
+	return &TokenSplitter{
+		counter:      counter,
+		chunkSize:    chunkSize,
+		chunkOverlap: chunkOverlap,
+	}, nil
+}
+
+// Split 切分文本
+func (t *TokenSplitter) Split(text string) []Segment {
+	return merge(text, tokenUnits(text), t.chunkSize, t.chunkOverlap, t.counter.Count)
+}
+
+// tokenUnits 把文本切成不可再分的最小单位
+func tokenUnits(text string) []span {
+	var units []span
+	start := 0
+	inWord := false
+
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			inWord = false
+			continue
+		case unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			inWord = false
+		case inWord:
+			continue
+		default:
+			inWord = true
+		}
+		// 新单位开始，前一个单位（含其后的空白）到此结束
+		if i > start {
+			units = append(units, span{start, i})
+		}
+		start = i
+	}
+	if start < len(text) {
+		units = append(units, span{start, len(text)})
+	}
+
+	// 开头的空白单独成段时并入第一个单位
+	if len(units) > 1 {
+		if r, _ := utf8.DecodeRuneInString(text[units[0].start:]); unicode.IsSpace(r) {
+			units[1].start = units[0].start
+			units = units[1:]
+		}
+	}
+	return units
+}
@@ -0,0 +1,222 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"goRag/internal/envconfig"
+)
+
+// BatchConfig 分批嵌入配置
+type BatchConfig struct {
+	MaxBatchSize  int // 每批最多的文本条数
+	MaxBatchChars int // 每批文本的总字符数上限（单条超长文本单独成批）
+	Concurrency   int // 同时进行的批次数
+}
+
+// DefaultBatchConfig 默认分批嵌入配置
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		MaxBatchSize:  64,
+		MaxBatchChars: 32000,
+		Concurrency:   4,
+	}
+}
+
+// NewBatchConfigFromEnv 从环境变量创建配置，未设置的项使用默认值，数值格式不正确时返回错误
+func NewBatchConfigFromEnv() (BatchConfig, error) {
+	config := DefaultBatchConfig()
+	var errs [3]error
+	config.MaxBatchSize, errs[0] = envconfig.Int("RAG_EMBED_BATCH_SIZE", config.MaxBatchSize)
+	config.MaxBatchChars, errs[1] = envconfig.Int("RAG_EMBED_BATCH_CHARS", config.MaxBatchChars)
+	config.Concurrency, errs[2] = envconfig.Int("RAG_EMBED_CONCURRENCY", config.Concurrency)
+	return config, errors.Join(errs[:]...)
+}
+
+// BatchError 部分批次嵌入失败
+// EmbedTexts 返回该错误时，结果中成功的位置仍然有向量，失败的位置为 nil，调用方可以只重试 Failed 中的文本。
+type BatchError struct {
+	Failed []int // 失败文本在输入中的下标（升序）
+	Total  int   // 输入文本总数
+	Err    error // 第一个失败批次的错误
+}
+
+// Error 实现 error 接口
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("failed to embed %d of %d texts (indices %s): %v", len(e.Failed), e.Total, formatIndices(e.Failed), e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// batch 一个批次在输入中的下标范围 [start, end)
+type batch struct {
+	start, end int
+}
+
+// BatchingEmbedder 分批嵌入器
+// 把大量文本按条数和总字符数切成多个批次，用有界协程池并发调用底层嵌入器，结果顺序与输入一致。
+// 避免一次添加上千篇文档时产生单个超大请求而超时。
+type BatchingEmbedder struct {
+	inner  Embedder
+	config BatchConfig
+}
+
+// NewBatchingEmbedder 创建分批嵌入器，config 中 <= 0 的项使用默认值
+func NewBatchingEmbedder(inner Embedder, config BatchConfig) (*BatchingEmbedder, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("embedder cannot be nil")
+	}
+
+	defaults := DefaultBatchConfig()
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = defaults.MaxBatchSize
+	}
+	if config.MaxBatchChars <= 0 {
+		config.MaxBatchChars = defaults.MaxBatchChars
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaults.Concurrency
+	}
+
+	return &BatchingEmbedder{
+		inner:  inner,
+		config: config,
+	}, nil
+}
+
+// EmbedText 将文本转换为向量嵌入
+func (b *BatchingEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	return b.inner.EmbedText(ctx, text)
+}
+
+// EmbedQuery 将检索查询转换为向量嵌入
+func (b *BatchingEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return b.inner.EmbedQuery(ctx, query)
+}
+
+// EmbedTexts 分批并发嵌入，部分批次失败时返回 *BatchError
+func (b *BatchingEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	batches := b.split(texts)
+	if len(batches) <= 1 {
+		return b.inner.EmbedTexts(ctx, texts)
+	}
+
+	results := make([][]float32, len(texts))
+	errs := make([]error, len(batches))
+
+	// 有界协程池：jobs 通道分发批次，ctx 取消后停止分发
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(b.config.Concurrency, len(batches)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = b.embedBatch(ctx, texts, results, batches[i])
+			}
+		}()
+	}
+
+	dispatched := 0
+dispatch:
+	for i := range batches {
+		select {
+		case jobs <- i:
+			dispatched++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// 未分发的批次记为 ctx 错误
+	for i := dispatched; i < len(batches); i++ {
+		errs[i] = ctx.Err()
+	}
+
+	var batchErr *BatchError
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if batchErr == nil {
+			batchErr = &BatchError{Total: len(texts), Err: err}
+		}
+		for j := batches[i].start; j < batches[i].end; j++ {
+			batchErr.Failed = append(batchErr.Failed, j)
+		}
+	}
+	if batchErr != nil {
+		return results, batchErr
+	}
+	return results, nil
+}
+
+// embedBatch 嵌入一个批次，结果写入 results 对应位置
+func (b *BatchingEmbedder) embedBatch(ctx context.Context, texts []string, results [][]float32, r batch) error {
+	vectors, err := b.inner.EmbedTexts(ctx, texts[r.start:r.end])
+	if err != nil {
+		return err
+	}
+	if len(vectors) != r.end-r.start {
+		return fmt.Errorf("mismatched number of embeddings: expected %d, got %d", r.end-r.start, len(vectors))
+	}
+	copy(results[r.start:r.end], vectors)
+	return nil
+}
+
+// split 按条数和总字符数切分批次
+func (b *BatchingEmbedder) split(texts []string) []batch {
+	var batches []batch
+	start, chars := 0, 0
+	for i, text := range texts {
+		n := utf8.RuneCountInString(text)
+		if i > start && (i-start >= b.config.MaxBatchSize || chars+n > b.config.MaxBatchChars) {
+			batches = append(batches, batch{start: start, end: i})
+			start, chars = i, 0
+		}
+		chars += n
+	}
+	if start < len(texts) {
+		batches = append(batches, batch{start: start, end: len(texts)})
+	}
+	return batches
+}
+
+// GetDimension 返回嵌入向量的维度
+func (b *BatchingEmbedder) GetDimension() int {
+	return b.inner.GetDimension()
+}
+
+// GetModelName 返回嵌入模型名称
+func (b *BatchingEmbedder) GetModelName() string {
+	return b.inner.GetModelName()
+}
+
+// formatIndices 把升序下标格式化为区间形式，如 "0-63,128-191"
+func formatIndices(indices []int) string {
+	var s []byte
+	for i := 0; i < len(indices); {
+		j := i
+		for j+1 < len(indices) && indices[j+1] == indices[j]+1 {
+			j++
+		}
+		if len(s) > 0 {
+			s = append(s, ',')
+		}
+		if i == j {
+			s = fmt.Appendf(s, "%d", indices[i])
+		} else {
+			s = fmt.Appendf(s, "%d-%d", indices[i], indices[j])
+		}
+		i = j + 1
+	}
+	return string(s)
+}
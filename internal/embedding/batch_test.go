@@ -0,0 +1,137 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBatchingEmbedderSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   BatchConfig
+		texts    []string
+		expected []batch
+	}{
+		{
+			name:     "empty",
+			config:   BatchConfig{MaxBatchSize: 2, MaxBatchChars: 100},
+			texts:    nil,
+			expected: nil,
+		},
+		{
+			name:     "count limit",
+			config:   BatchConfig{MaxBatchSize: 2, MaxBatchChars: 100},
+			texts:    []string{"a", "b", "c", "d", "e"},
+			expected: []batch{{0, 2}, {2, 4}, {4, 5}},
+		},
+		{
+			name:     "char limit",
+			config:   BatchConfig{MaxBatchSize: 10, MaxBatchChars: 5},
+			texts:    []string{"aa", "bb", "cc", "d", "eeee"},
+			expected: []batch{{0, 2}, {2, 4}, {4, 5}},
+		},
+		{
+			name:     "chars counted as runes",
+			config:   BatchConfig{MaxBatchSize: 10, MaxBatchChars: 4},
+			texts:    []string{"中文", "测试", "向量"},
+			expected: []batch{{0, 2}, {2, 3}},
+		},
+		{
+			name:     "oversized single text",
+			config:   BatchConfig{MaxBatchSize: 10, MaxBatchChars: 5},
+			texts:    []string{"a", strings.Repeat("x", 20), "b"},
+			expected: []batch{{0, 1}, {1, 2}, {2, 3}},
+		},
+		{
+			name:     "oversized first text",
+			config:   BatchConfig{MaxBatchSize: 10, MaxBatchChars: 5},
+			texts:    []string{strings.Repeat("x", 20), "a", "b"},
+			expected: []batch{{0, 1}, {1, 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBatchingEmbedder(newFakeEmbedder(), tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.split(tt.texts); !slices.Equal(got, tt.expected) {
+				t.Errorf("split = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBatchingEmbedderPreservesOrder(t *testing.T) {
+	inner := newFakeEmbedder()
+	b, err := NewBatchingEmbedder(inner, BatchConfig{MaxBatchSize: 3, MaxBatchChars: 1000, Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	texts := []string{"a", "bb", "ccc", "dddd", "e", "ff", "g"}
+	vectors, err := b.EmbedTexts(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range texts {
+		if !slices.Equal(vectors[i], fakeVector(text)) {
+			t.Errorf("vector %d = %v, want %v", i, vectors[i], fakeVector(text))
+		}
+	}
+	if len(inner.calls) != 3 {
+		t.Errorf("got %d inner calls, want 3", len(inner.calls))
+	}
+}
+
+func TestBatchingEmbedderPartialFailure(t *testing.T) {
+	inner := newFakeEmbedder()
+	inner.fail = map[string]bool{"bad": true}
+	b, err := NewBatchingEmbedder(inner, BatchConfig{MaxBatchSize: 2, MaxBatchChars: 1000, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	texts := []string{"a", "b", "c", "bad", "e"}
+	vectors, err := b.EmbedTexts(context.Background(), texts)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected *BatchError, got %v", err)
+	}
+	if want := []int{2, 3}; !slices.Equal(batchErr.Failed, want) || batchErr.Total != len(texts) {
+		t.Errorf("BatchError = %+v, want Failed %v Total %d", batchErr, want, len(texts))
+	}
+	if !strings.Contains(batchErr.Error(), "indices 2-3") {
+		t.Errorf("error message %q should list failed indices", batchErr.Error())
+	}
+	for _, i := range []int{0, 1, 4} {
+		if vectors[i] == nil {
+			t.Errorf("vector %d from a successful batch should be set", i)
+		}
+	}
+	for _, i := range batchErr.Failed {
+		if vectors[i] != nil {
+			t.Errorf("vector %d from the failed batch should be nil", i)
+		}
+	}
+}
+
+func TestFormatIndices(t *testing.T) {
+	tests := []struct {
+		indices  []int
+		expected string
+	}{
+		{nil, ""},
+		{[]int{3}, "3"},
+		{[]int{0, 1, 2, 5, 7, 8}, "0-2,5,7-8"},
+	}
+	for _, tt := range tests {
+		if got := formatIndices(tt.indices); got != tt.expected {
+			t.Errorf("formatIndices(%v) = %q, want %q", tt.indices, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package embedding
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+
+	"goRag/internal/envconfig"
+)
+
+// DefaultCacheCapacity 内存缓存默认容量（向量条数）
+const DefaultCacheCapacity = 10000
+
+// CacheConfig 嵌入缓存配置
+type CacheConfig struct {
+	Capacity int    // 内存 LRU 容量（向量条数），<= 0 时不使用内存缓存
+	Dir      string // 磁盘缓存目录，为空时不持久化；磁盘缓存没有容量上限，见 diskCache
+}
+
+// NewCacheConfigFromEnv 从环境变量创建配置，数值格式不正确时返回错误
+func NewCacheConfigFromEnv() (*CacheConfig, error) {
+	capacity, err := envconfig.Int("RAG_EMBED_CACHE_SIZE", DefaultCacheCapacity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CacheConfig{
+		Capacity: capacity,
+		Dir:      os.Getenv("RAG_EMBED_CACHE_DIR"),
+	}, nil
+}
+
+// CacheStats 嵌入缓存统计
+type CacheStats struct {
+	Hits        int64 // 内存缓存命中次数
+	DiskHits    int64 // 磁盘缓存命中次数
+	Misses      int64 // 未命中、调用底层嵌入器的次数
+	Entries     int   // 内存缓存中的向量数
+	DiskEntries int   // 磁盘缓存中的向量数
+}
+
+// cacheEntry LRU 链表中的一项
+type cacheEntry struct {
+	key    string
+	vector []float32
+}
+
+// CachedEmbedder 带缓存的嵌入器
+// 按 "模型名 + 文本内容" 的哈希缓存向量：先查内存 LRU，再查磁盘缓存，都未命中时才调用底层嵌入器。
+// 底层嵌入器的模型名或维度变化时，已缓存的向量全部作废。
+type CachedEmbedder struct {
+	inner Embedder
+
+	mu        sync.Mutex
+	capacity  int
+	lru       *list.List // 最近使用的在前
+	items     map[string]*list.Element
+	disk      *diskCache
+	model     string
+	dimension int
+	stats     CacheStats
+}
+
+// NewCachedEmbedder 创建带缓存的嵌入器
+func NewCachedEmbedder(inner Embedder, config *CacheConfig) (*CachedEmbedder, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("embedder cannot be nil")
+	}
+	if config == nil {
+		var err error
+		if config, err = NewCacheConfigFromEnv(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &CachedEmbedder{
+		inner:     inner,
+		capacity:  config.Capacity,
+		lru:       list.New(),
+		items:     make(map[string]*list.Element),
+		model:     inner.GetModelName(),
+		dimension: inner.GetDimension(),
+	}
+
+	if config.Dir != "" {
+		disk, err := openDiskCache(config.Dir, c.model, c.dimension)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+	}
+
+	return c, nil
+}
+
+// EmbedText 将文档文本转换为向量嵌入
+func (c *CachedEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := c.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EmbedQuery 将检索查询转换为向量嵌入，查询和文档的向量分开缓存
+func (c *CachedEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := c.embed(ctx, []string{query}, true)
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EmbedTexts 批量将文档文本转换为向量嵌入，只把未命中缓存的文本交给底层嵌入器
+// 同一批次中重复的文本只嵌入一次。底层返回 *BatchError 时，同样返回部分结果和以输入下标表示的 *BatchError。
+func (c *CachedEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	return c.embed(ctx, texts, false)
+}
+
+// embed 查缓存并嵌入未命中的文本，query 为 true 时按查询嵌入
+// 返回的向量都是副本，调用方修改不会影响缓存；读写磁盘缓存时不持有 c.mu。
+func (c *CachedEmbedder) embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	results := make([][]float32, len(texts))
+
+	// 1. 查内存缓存，收集未命中的文本（去重）
+	var missTexts, missKeys []string
+	missIndices := make(map[string][]int) // key -> 结果中的位置
+	c.mu.Lock()
+	c.checkModelLocked()
+	model, dimension, disk := c.model, c.dimension, c.disk
+	for i, text := range texts {
+		key := c.key(text, query)
+		if vector, ok := c.getLocked(key); ok {
+			results[i] = slices.Clone(vector)
+			continue
+		}
+		if _, pending := missIndices[key]; !pending {
+			missTexts = append(missTexts, text)
+			missKeys = append(missKeys, key)
+			if disk == nil {
+				c.stats.Misses++
+			}
+		}
+		missIndices[key] = append(missIndices[key], i)
+	}
+	c.mu.Unlock()
+
+	// 2. 查磁盘缓存，命中的向量放入内存缓存
+	if disk != nil && len(missKeys) > 0 {
+		found := make(map[string][]float32)
+		for _, key := range missKeys {
+			vector, err := disk.get(key)
+			if err != nil {
+				log.Printf("embedding cache: failed to read disk cache: %v", err)
+				continue
+			}
+			if vector != nil && (dimension <= 0 || len(vector) == dimension) {
+				found[key] = vector
+			}
+		}
+
+		missTexts, missKeys = fillResults(results, missTexts, missKeys, missIndices, found)
+
+		c.mu.Lock()
+		cacheable := c.model == model && c.dimension == dimension
+		if cacheable {
+			for key, vector := range found {
+				c.addLocked(key, vector)
+			}
+		}
+		c.stats.DiskHits += int64(len(found))
+		c.stats.Misses += int64(len(missKeys))
+		c.mu.Unlock()
+	}
+
+	if len(missTexts) == 0 {
+		return results, nil
+	}
+
+	// 3. 嵌入未命中的文本（不持有锁）
+	var vectors [][]float32
+	var err error
+	if query {
+		var vector []float32
+		vector, err = c.inner.EmbedQuery(ctx, missTexts[0])
+		vectors = [][]float32{vector}
+	} else {
+		vectors, err = c.inner.EmbedTexts(ctx, missTexts)
+	}
+	var batchErr *BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		return nil, err
+	}
+	if len(vectors) != len(missTexts) {
+		return nil, fmt.Errorf("mismatched number of embeddings: expected %d, got %d", len(missTexts), len(vectors))
+	}
+
+	// 4. 写回缓存；部分批次失败时只缓存成功的向量，并把失败下标换算为输入中的下标
+	// 嵌入期间模型发生变化时无法确定向量属于哪个模型，只返回结果不写缓存
+	embedded := make(map[string][]float32, len(missKeys))
+	var failed []int
+	for j, key := range missKeys {
+		if vectors[j] == nil {
+			failed = append(failed, missIndices[key]...)
+			continue
+		}
+		embedded[key] = vectors[j]
+	}
+	c.mu.Lock()
+	c.checkModelLocked()
+	cacheable := c.model == model && c.dimension == dimension
+	disk = c.disk
+	if cacheable {
+		for key, vector := range embedded {
+			c.putLocked(key, vector)
+		}
+	}
+	c.mu.Unlock()
+	if cacheable && disk != nil {
+		for key, vector := range embedded {
+			if err := disk.put(key, vector); err != nil {
+				log.Printf("embedding cache: failed to write disk cache: %v", err)
+			}
+		}
+	}
+	fillResults(results, missTexts, missKeys, missIndices, embedded)
+
+	if batchErr != nil {
+		sort.Ints(failed)
+		return results, &BatchError{Failed: failed, Total: len(texts), Err: batchErr.Err}
+	}
+	return results, nil
+}
+
+// fillResults 把 vectors 中的向量按副本填入结果的对应位置，返回 vectors 中没有的文本和键
+func fillResults(results [][]float32, texts, keys []string, indices map[string][]int, vectors map[string][]float32) ([]string, []string) {
+	var restTexts, restKeys []string
+	for j, key := range keys {
+		vector, ok := vectors[key]
+		if !ok {
+			restTexts = append(restTexts, texts[j])
+			restKeys = append(restKeys, key)
+			continue
+		}
+		for _, i := range indices[key] {
+			results[i] = slices.Clone(vector)
+		}
+	}
+	return restTexts, restKeys
+}
+
+// GetDimension 返回嵌入向量的维度
+func (c *CachedEmbedder) GetDimension() int {
+	return c.inner.GetDimension()
+}
+
+// GetModelName 返回嵌入模型名称
+func (c *CachedEmbedder) GetModelName() string {
+	return c.inner.GetModelName()
+}
+
+// Stats 返回缓存统计
+func (c *CachedEmbedder) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	if c.disk != nil {
+		stats.DiskEntries = c.disk.len()
+	}
+	return stats
+}
+
+// Close 关闭磁盘缓存文件
+func (c *CachedEmbedder) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.disk == nil {
+		return nil
+	}
+	return c.disk.close()
+}
+
+// key 计算缓存键：模型名、嵌入模式和文本内容的 SHA-256
+func (c *CachedEmbedder) key(text string, query bool) string {
+	h := sha256.New()
+	h.Write([]byte(c.model))
+	h.Write([]byte{0})
+	if query {
+		h.Write([]byte("query"))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkModelLocked 底层嵌入器的模型名或维度变化时清空缓存（调用方需持有锁）
+func (c *CachedEmbedder) checkModelLocked() {
+	model, dimension := c.inner.GetModelName(), c.inner.GetDimension()
+	if model == c.model && dimension == c.dimension {
+		return
+	}
+
+	log.Printf("embedding cache: model changed from %s/%d to %s/%d, invalidating cache",
+		c.model, c.dimension, model, dimension)
+	c.model, c.dimension = model, dimension
+	c.lru.Init()
+	c.items = make(map[string]*list.Element)
+	if c.disk != nil {
+		if err := c.disk.reset(model, dimension); err != nil {
+			log.Printf("embedding cache: failed to reset disk cache, disabling it: %v", err)
+			c.disk.close()
+			c.disk = nil
+		}
+	}
+}
+
+// getLocked 查内存缓存，维度不一致的向量视为未命中（调用方需持有锁）
+// 返回的是缓存中的向量本身，交给调用方之前需要复制
+func (c *CachedEmbedder) getLocked(key string) ([]float32, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !c.validLocked(entry.vector) {
+		c.lru.Remove(elem)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	c.stats.Hits++
+	return entry.vector, true
+}
+
+// putLocked 把新嵌入的向量的副本放入内存缓存，磁盘缓存由调用方在锁外写入（调用方需持有锁）
+func (c *CachedEmbedder) putLocked(key string, vector []float32) {
+	if !c.validLocked(vector) {
+		return
+	}
+	c.addLocked(key, slices.Clone(vector))
+}
+
+// addLocked 放入内存 LRU，超出容量时淘汰最久未使用的向量（调用方需持有锁）
+func (c *CachedEmbedder) addLocked(key string, vector []float32) {
+	if c.capacity <= 0 {
+		return
+	}
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*cacheEntry).vector = vector
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, vector: vector})
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// validLocked 向量维度与当前模型一致（维度未知时不校验）
+func (c *CachedEmbedder) validLocked(vector []float32) bool {
+	return c.dimension <= 0 || len(vector) == c.dimension
+}
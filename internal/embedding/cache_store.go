@@ -0,0 +1,208 @@
+package embedding
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	cacheFormatVersion = 1
+	cacheFileName      = "embeddings.jsonl"
+)
+
+// cacheHeader 磁盘缓存文件头，记录写入时使用的嵌入模型
+type cacheHeader struct {
+	Version   int    `json:"version"`
+	Model     string `json:"model"`
+	Dimension int    `json:"dimension"`
+}
+
+// cacheRecord 磁盘缓存中的一条向量
+type cacheRecord struct {
+	Key    string    `json:"key"`
+	Vector []float32 `json:"vector"`
+}
+
+// recordLocation 记录在文件中的位置
+type recordLocation struct {
+	offset int64
+	length int
+}
+
+// diskCache 基于文件的嵌入缓存
+// 文件第一行为文件头，之后每行一条向量，只追加不修改；内存中只保存每条记录的偏移，读取时按偏移读回。
+// 文件头中的模型名或维度与当前模型不一致时清空文件。
+//
+// 磁盘缓存不淘汰：同一个键只写入一次，文件中的每条记录都在索引中，因此压缩无法回收空间，
+// 文件大小随嵌入过的不同文本数增长（1024 维约 10KB/条）。需要回收空间时停止服务后删除缓存目录即可，
+// 启动时会重新创建，代价只是重新嵌入。
+//
+// diskCache 自带锁，CachedEmbedder 在自己的锁之外读写磁盘，磁盘 I/O 不会阻塞内存缓存命中。
+type diskCache struct {
+	mu        sync.Mutex
+	file      *os.File
+	size      int64
+	dimension int // 文件头中的维度，put 拒绝维度不一致的向量
+	index     map[string]recordLocation
+}
+
+// openDiskCache 打开（或创建）磁盘缓存
+func openDiskCache(dir, model string, dimension int) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, cacheFileName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+
+	d := &diskCache{file: f, dimension: dimension, index: make(map[string]recordLocation)}
+	valid, err := d.load(model, dimension)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to load embedding cache: %w", err)
+	}
+	if !valid {
+		if err := d.reset(model, dimension); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// load 扫描文件建立索引，文件为空或文件头与当前模型不一致时返回 false
+// 末尾不完整的一行（写入中途崩溃）会被截掉
+func (d *diskCache) load(model string, dimension int) (bool, error) {
+	reader := bufio.NewReader(d.file)
+	var offset int64
+	first := true
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("embedding cache: ignoring truncated trailing record")
+				if err := d.file.Truncate(offset); err != nil {
+					return false, err
+				}
+			}
+			d.size = offset
+			return !first, nil
+		}
+
+		if first {
+			var header cacheHeader
+			if err := json.Unmarshal(line, &header); err != nil || header.Version != cacheFormatVersion ||
+				header.Model != model || header.Dimension != dimension {
+				log.Printf("embedding cache: stored %s/%d does not match %s/%d, invalidating cache",
+					header.Model, header.Dimension, model, dimension)
+				return false, nil
+			}
+			first = false
+		} else {
+			var record cacheRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				log.Printf("embedding cache: invalid record at offset %d, invalidating cache: %v", offset, err)
+				return false, nil
+			}
+			d.index[record.Key] = recordLocation{offset: offset, length: len(line)}
+		}
+		offset += int64(len(line))
+	}
+}
+
+// reset 清空文件并写入新的文件头
+func (d *diskCache) reset(model string, dimension int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate embedding cache: %w", err)
+	}
+	d.size = 0
+	d.dimension = dimension
+	d.index = make(map[string]recordLocation)
+	if err := d.write(cacheHeader{Version: cacheFormatVersion, Model: model, Dimension: dimension}); err != nil {
+		return fmt.Errorf("failed to write embedding cache header: %w", err)
+	}
+	return nil
+}
+
+// get 读取向量，不存在时返回 nil
+func (d *diskCache) get(key string) ([]float32, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	loc, ok := d.index[key]
+	if !ok {
+		return nil, nil
+	}
+	buf := make([]byte, loc.length)
+	if _, err := d.file.ReadAt(buf, loc.offset); err != nil {
+		return nil, err
+	}
+	var record cacheRecord
+	if err := json.Unmarshal(buf, &record); err != nil {
+		return nil, err
+	}
+	return record.Vector, nil
+}
+
+// put 追加一条向量，已存在或维度与文件头不一致（嵌入期间模型变化、缓存已重置）时跳过
+// 缓存丢失只会导致重新嵌入，因此不逐条刷盘
+func (d *diskCache) put(key string, vector []float32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.index[key]; ok {
+		return nil
+	}
+	if d.dimension > 0 && len(vector) != d.dimension {
+		return nil
+	}
+	offset := d.size
+	if err := d.write(cacheRecord{Key: key, Vector: vector}); err != nil {
+		return err
+	}
+	d.index[key] = recordLocation{offset: offset, length: int(d.size - offset)}
+	return nil
+}
+
+// write 在文件末尾写入一行 JSON
+func (d *diskCache) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := d.file.WriteAt(data, d.size); err != nil {
+		return err
+	}
+	d.size += int64(len(data))
+	return nil
+}
+
+// len 返回缓存的向量数
+func (d *diskCache) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.index)
+}
+
+// close 关闭文件
+func (d *diskCache) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.file.Close()
+}
@@ -0,0 +1,358 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+var errFakeEmbed = errors.New("fake embed failure")
+
+// fakeEmbedder 记录调用的测试嵌入器，文本的向量由内容决定
+type fakeEmbedder struct {
+	mu        sync.Mutex
+	model     string
+	dimension int
+	calls     [][]string
+	queries   []string
+	fail      map[string]bool // 批次中包含这些文本时整批失败
+	onEmbed   func(f *fakeEmbedder)
+}
+
+func newFakeEmbedder() *fakeEmbedder {
+	return &fakeEmbedder{model: "fake", dimension: 2}
+}
+
+// fakeVector 文本的测试向量：[长度, 首字节]
+func fakeVector(text string) []float32 {
+	v := []float32{float32(len(text)), 0}
+	if text != "" {
+		v[1] = float32(text[0])
+	}
+	return v
+}
+
+func (f *fakeEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := f.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (f *fakeEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, slices.Clone(texts))
+	hook := f.onEmbed
+	f.mu.Unlock()
+	if hook != nil {
+		hook(f)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if f.fail[text] {
+			return nil, errFakeEmbed
+		}
+		vectors[i] = fakeVector(text)
+	}
+	return vectors, nil
+}
+
+func (f *fakeEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	f.mu.Lock()
+	f.queries = append(f.queries, query)
+	f.mu.Unlock()
+	return fakeVector(query), nil
+}
+
+func (f *fakeEmbedder) GetDimension() int {
+	return f.dimension
+}
+
+func (f *fakeEmbedder) GetModelName() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.model
+}
+
+// embedded 返回底层嵌入器收到的全部文档文本
+func (f *fakeEmbedder) embedded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, call := range f.calls {
+		texts = append(texts, call...)
+	}
+	return texts
+}
+
+func TestCachedEmbedderLRUEviction(t *testing.T) {
+	inner := newFakeEmbedder()
+	cache, err := NewCachedEmbedder(inner, &CacheConfig{Capacity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// a、b 入缓存，再次访问 a 使 b 成为最久未使用，加入 c 时淘汰 b
+	for _, text := range []string{"a", "b", "a", "c"} {
+		if _, err := cache.EmbedText(ctx, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := inner.embedded(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("embedded %v, want %v", got, want)
+	}
+
+	for _, text := range []string{"a", "c", "b"} {
+		if _, err := cache.EmbedText(ctx, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := inner.embedded(), []string{"a", "b", "c", "b"}; !slices.Equal(got, want) {
+		t.Errorf("embedded %v, want %v (only the evicted text re-embedded)", got, want)
+	}
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Hits != 3 || stats.Misses != 4 {
+		t.Errorf("stats = %+v, want 2 entries, 3 hits, 4 misses", stats)
+	}
+}
+
+func TestCachedEmbedderDeduplicatesAndSeparatesQueries(t *testing.T) {
+	inner := newFakeEmbedder()
+	cache, err := NewCachedEmbedder(inner, &CacheConfig{Capacity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	vectors, err := cache.EmbedTexts(ctx, []string{"x", "y", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := inner.embedded(), []string{"x", "y"}; !slices.Equal(got, want) {
+		t.Errorf("embedded %v, want %v", got, want)
+	}
+	if !slices.Equal(vectors[0], vectors[2]) {
+		t.Errorf("duplicate texts got different vectors: %v, %v", vectors[0], vectors[2])
+	}
+
+	// 查询和文档分开缓存：同样的文本按查询嵌入时仍然调用 EmbedQuery
+	for i := 0; i < 2; i++ {
+		if _, err := cache.EmbedQuery(ctx, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := inner.queries, []string{"x"}; !slices.Equal(got, want) {
+		t.Errorf("queries %v, want %v", got, want)
+	}
+}
+
+func TestCachedEmbedderRemapsBatchError(t *testing.T) {
+	inner := newFakeEmbedder()
+	inner.fail = map[string]bool{"bad": true}
+	batching, err := NewBatchingEmbedder(inner, BatchConfig{MaxBatchSize: 1, MaxBatchChars: 100, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewCachedEmbedder(batching, &CacheConfig{Capacity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := cache.EmbedText(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未命中的文本为 [bad a b]，底层失败下标 0 对应输入中的 1 和 3
+	texts := []string{"c", "bad", "a", "bad", "b"}
+	vectors, err := cache.EmbedTexts(ctx, texts)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected *BatchError, got %v", err)
+	}
+	if want := []int{1, 3}; !slices.Equal(batchErr.Failed, want) || batchErr.Total != len(texts) {
+		t.Errorf("BatchError = %+v, want Failed %v Total %d", batchErr, want, len(texts))
+	}
+	if !errors.Is(err, errFakeEmbed) {
+		t.Errorf("BatchError should wrap the underlying error, got %v", batchErr.Err)
+	}
+	for i, text := range texts {
+		if text == "bad" {
+			if vectors[i] != nil {
+				t.Errorf("vector %d for failed text should be nil", i)
+			}
+		} else if !slices.Equal(vectors[i], fakeVector(text)) {
+			t.Errorf("vector %d = %v, want %v", i, vectors[i], fakeVector(text))
+		}
+	}
+
+	// 成功的文本已缓存，只有失败的文本会重试
+	before := len(inner.embedded())
+	cache.EmbedTexts(ctx, texts)
+	if got, want := inner.embedded()[before:], []string{"bad"}; !slices.Equal(got, want) {
+		t.Errorf("retry embedded %v, want %v", got, want)
+	}
+}
+
+func TestCachedEmbedderSkipsCacheWhenModelChangesMidEmbed(t *testing.T) {
+	inner := newFakeEmbedder()
+	cache, err := NewCachedEmbedder(inner, &CacheConfig{Capacity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner.onEmbed = func(f *fakeEmbedder) {
+		f.mu.Lock()
+		f.model = "fake-v2"
+		f.onEmbed = nil
+		f.mu.Unlock()
+	}
+
+	vectors, err := cache.EmbedTexts(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(vectors[1], fakeVector("b")) {
+		t.Errorf("vector = %v, want %v", vectors[1], fakeVector("b"))
+	}
+	if entries := cache.Stats().Entries; entries != 0 {
+		t.Errorf("vectors embedded across a model change should not be cached, got %d entries", entries)
+	}
+}
+
+func TestCachedEmbedderDiskTier(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	inner := newFakeEmbedder()
+	cache, err := NewCachedEmbedder(inner, &CacheConfig{Capacity: 10, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.EmbedTexts(ctx, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	cache.Close()
+
+	// 重新打开后从磁盘命中
+	inner = newFakeEmbedder()
+	cache, err = NewCachedEmbedder(inner, &CacheConfig{Capacity: 10, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors, err := cache.EmbedTexts(ctx, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.embedded()) != 0 {
+		t.Errorf("expected disk hits, embedded %v", inner.embedded())
+	}
+	if !slices.Equal(vectors[0], fakeVector("a")) {
+		t.Errorf("vector = %v, want %v", vectors[0], fakeVector("a"))
+	}
+	if stats := cache.Stats(); stats.DiskHits != 2 || stats.DiskEntries != 2 {
+		t.Errorf("stats = %+v, want 2 disk hits and 2 disk entries", stats)
+	}
+	cache.Close()
+
+	// 模型变化后磁盘缓存作废
+	inner = newFakeEmbedder()
+	inner.model = "other"
+	cache, err = NewCachedEmbedder(inner, &CacheConfig{Capacity: 10, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if _, err := cache.EmbedText(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := inner.embedded(), []string{"a"}; !slices.Equal(got, want) {
+		t.Errorf("embedded %v after model change, want %v", got, want)
+	}
+}
+
+func TestCachedEmbedderReturnsCopies(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	cache, err := NewCachedEmbedder(newFakeEmbedder(), &CacheConfig{Capacity: 10, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新嵌入的、同批次重复的、内存命中的向量都是各自的副本
+	vectors, err := cache.EmbedTexts(ctx, []string{"a", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors[0][0] = 100
+	if !slices.Equal(vectors[1], fakeVector("a")) {
+		t.Errorf("duplicate text shares the vector: %v", vectors[1])
+	}
+	hit, err := cache.EmbedText(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hit[1] = 100
+	if again, _ := cache.EmbedText(ctx, "a"); !slices.Equal(again, fakeVector("a")) {
+		t.Errorf("cached vector modified through a returned slice: %v", again)
+	}
+
+	// 磁盘命中的向量同样是副本
+	cache.Close()
+	cache, err = NewCachedEmbedder(newFakeEmbedder(), &CacheConfig{Capacity: 10, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	hit, err = cache.EmbedText(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hit[0] = 100
+	if again, _ := cache.EmbedText(ctx, "a"); !slices.Equal(again, fakeVector("a")) {
+		t.Errorf("disk hit vector modified through a returned slice: %v", again)
+	}
+	if stats := cache.Stats(); stats.DiskHits != 1 || stats.Hits != 1 {
+		t.Errorf("stats = %+v, want 1 disk hit and 1 memory hit", stats)
+	}
+}
+
+func TestCachedEmbedderConcurrentDiskTier(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeEmbedder()
+	cache, err := NewCachedEmbedder(inner, &CacheConfig{Capacity: 4, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	texts := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				text := texts[(g+i)%len(texts)]
+				vector, err := cache.EmbedText(ctx, text)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !slices.Equal(vector, fakeVector(text)) {
+					t.Errorf("vector for %s = %v, want %v", text, vector, fakeVector(text))
+				}
+				vector[0] = -1
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.DiskEntries != len(texts) {
+		t.Errorf("disk entries = %d, want %d", stats.DiskEntries, len(texts))
+	}
+}
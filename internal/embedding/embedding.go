@@ -5,15 +5,23 @@ import (
 )
 
 // Embedder 定义嵌入接口
+// 很多嵌入模型要求查询和文档使用不同的指令前缀（见 Instruction），
+// 因此区分两种用法：EmbedText/EmbedTexts 嵌入待检索的文档，EmbedQuery 嵌入检索时的查询。
 type Embedder interface {
-	// EmbedText 将文本转换为向量嵌入
+	// EmbedText 将文档文本转换为向量嵌入
 	EmbedText(ctx context.Context, text string) ([]float32, error)
 
-	// EmbedTexts 批量将文本转换为向量嵌入
+	// EmbedTexts 批量将文档文本转换为向量嵌入
 	EmbedTexts(ctx context.Context, texts []string) ([][]float32, error)
 
+	// EmbedQuery 将检索查询转换为向量嵌入
+	EmbedQuery(ctx context.Context, query string) ([]float32, error)
+
 	// GetDimension 返回嵌入向量的维度
 	GetDimension() int
+
+	// GetModelName 返回嵌入模型名称（用于持久化校验等场景）
+	GetModelName() string
 }
 
 // Service 嵌入服务
@@ -33,6 +41,11 @@ func (s *Service) Embed(ctx context.Context, text string) ([]float32, error) {
 	return s.embedder.EmbedText(ctx, text)
 }
 
+// EmbedQuery 嵌入检索查询
+func (s *Service) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return s.embedder.EmbedQuery(ctx, query)
+}
+
 // EmbedBatch 批量嵌入文本
 func (s *Service) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
 	return s.embedder.EmbedTexts(ctx, texts)
@@ -42,3 +55,8 @@ func (s *Service) EmbedBatch(ctx context.Context, texts []string) ([][]float32,
 func (s *Service) GetDimension() int {
 	return s.embedder.GetDimension()
 }
+
+// GetModelName 获取嵌入模型名称
+func (s *Service) GetModelName() string {
+	return s.embedder.GetModelName()
+}
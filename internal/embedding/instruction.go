@@ -0,0 +1,151 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Instruction 嵌入模型要求的指令前缀
+// 非对称检索模型（如 qwen3-embedding、bge、e5、nomic-embed-text）训练时给查询和文档加了不同的前缀，
+// 嵌入时不加前缀会明显降低召回效果。
+type Instruction struct {
+	Query    string // 查询前缀
+	Document string // 文档前缀
+}
+
+// IsZero 没有任何前缀
+func (i Instruction) IsZero() bool {
+	return i.Query == "" && i.Document == ""
+}
+
+// DefaultInstructions 常见嵌入模型的指令前缀，按模型名前缀匹配，最长前缀优先
+func DefaultInstructions() map[string]Instruction {
+	return map[string]Instruction{
+		"qwen3-embedding": {
+			Query: "Instruct: Given a web search query, retrieve relevant passages that answer the query\nQuery:",
+		},
+		"nomic-embed-text": {
+			Query:    "search_query: ",
+			Document: "search_document: ",
+		},
+		"mxbai-embed-large": {
+			Query: "Represent this sentence for searching relevant passages: ",
+		},
+		"bge-large-zh": {
+			Query: "为这个句子生成表示以用于检索相关文章：",
+		},
+		"bge-large-en": {
+			Query: "Represent this sentence for searching relevant passages: ",
+		},
+		"multilingual-e5": {
+			Query:    "query: ",
+			Document: "passage: ",
+		},
+		"e5-": {
+			Query:    "query: ",
+			Document: "passage: ",
+		},
+	}
+}
+
+// LookupInstruction 按模型名查找指令前缀，未匹配时返回零值
+// 模型名可以带命名空间和标签，如 "dengcao/Qwen3-Embedding-0.6B:Q8_0"，匹配时忽略大小写和命名空间。
+func LookupInstruction(model string, instructions map[string]Instruction) Instruction {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	var result Instruction
+	matched := 0
+	for prefix, instruction := range instructions {
+		if strings.HasPrefix(name, prefix) && len(prefix) > matched {
+			result, matched = instruction, len(prefix)
+		}
+	}
+	return result
+}
+
+// NewInstructionFromEnv 根据模型名和环境变量确定指令前缀
+// RAG_EMBED_QUERY_PREFIX、RAG_EMBED_DOCUMENT_PREFIX 设置时（包括设置为空字符串）覆盖内置的前缀。
+func NewInstructionFromEnv(model string) Instruction {
+	instruction := LookupInstruction(model, DefaultInstructions())
+	if v, ok := os.LookupEnv("RAG_EMBED_QUERY_PREFIX"); ok {
+		instruction.Query = v
+	}
+	if v, ok := os.LookupEnv("RAG_EMBED_DOCUMENT_PREFIX"); ok {
+		instruction.Document = v
+	}
+	return instruction
+}
+
+// DocumentInstructor 嵌入文档时会加指令前缀的嵌入器
+// 持久化存储用它记录文档前缀：前缀变化后，已保存的向量与新向量不再可比。
+type DocumentInstructor interface {
+	// DocumentInstruction 返回嵌入文档时使用的指令前缀
+	DocumentInstruction() string
+}
+
+// InstructionEmbedder 给查询和文档加上模型要求的指令前缀后再嵌入
+// 应包在 CachedEmbedder 外层，这样缓存键包含前缀，修改前缀后不会命中旧向量。
+type InstructionEmbedder struct {
+	inner       Embedder
+	instruction Instruction
+}
+
+// NewInstructionEmbedder 创建加指令前缀的嵌入器
+func NewInstructionEmbedder(inner Embedder, instruction Instruction) (*InstructionEmbedder, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("embedder cannot be nil")
+	}
+
+	return &InstructionEmbedder{
+		inner:       inner,
+		instruction: instruction,
+	}, nil
+}
+
+// Instruction 返回使用的指令前缀
+func (e *InstructionEmbedder) Instruction() Instruction {
+	return e.instruction
+}
+
+// DocumentInstruction 实现 DocumentInstructor
+func (e *InstructionEmbedder) DocumentInstruction() string {
+	return e.instruction.Document
+}
+
+// EmbedText 将文档文本加上文档前缀后转换为向量嵌入
+func (e *InstructionEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	return e.inner.EmbedText(ctx, e.instruction.Document+text)
+}
+
+// EmbedTexts 批量将文档文本加上文档前缀后转换为向量嵌入
+func (e *InstructionEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.instruction.Document == "" {
+		return e.inner.EmbedTexts(ctx, texts)
+	}
+
+	prefixed := make([]string, len(texts))
+	for i, text := range texts {
+		prefixed[i] = e.instruction.Document + text
+	}
+	return e.inner.EmbedTexts(ctx, prefixed)
+}
+
+// EmbedQuery 将检索查询加上查询前缀后转换为向量嵌入
+func (e *InstructionEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return e.inner.EmbedQuery(ctx, e.instruction.Query+query)
+}
+
+// GetDimension 返回嵌入向量的维度
+func (e *InstructionEmbedder) GetDimension() int {
+	return e.inner.GetDimension()
+}
+
+// GetModelName 返回嵌入模型名称
+func (e *InstructionEmbedder) GetModelName() string {
+	return e.inner.GetModelName()
+}
@@ -0,0 +1,115 @@
+package embedding
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestLookupInstruction(t *testing.T) {
+	instructions := DefaultInstructions()
+	tests := []struct {
+		model string
+		want  Instruction
+	}{
+		{"qwen3-embedding:0.6b", instructions["qwen3-embedding"]},
+		{"dengcao/Qwen3-Embedding-0.6B:Q8_0", instructions["qwen3-embedding"]},
+		{"nomic-embed-text:v1.5", Instruction{Query: "search_query: ", Document: "search_document: "}},
+		{"multilingual-e5-large", instructions["multilingual-e5"]},
+		{"intfloat/E5-large-v2", Instruction{Query: "query: ", Document: "passage: "}},
+		{"bge-large-zh-v1.5", Instruction{Query: "为这个句子生成表示以用于检索相关文章："}},
+		{"all-minilm", Instruction{}},
+		{"", Instruction{}},
+	}
+	for _, tt := range tests {
+		if got := LookupInstruction(tt.model, instructions); got != tt.want {
+			t.Errorf("LookupInstruction(%q) = %+v, want %+v", tt.model, got, tt.want)
+		}
+	}
+
+	// 最长前缀优先
+	custom := map[string]Instruction{"bge": {Query: "short"}, "bge-m3": {Query: "long"}}
+	if got := LookupInstruction("bge-m3:latest", custom); got.Query != "long" {
+		t.Errorf("LookupInstruction picked %q, want the longest prefix", got.Query)
+	}
+}
+
+func TestNewInstructionFromEnv(t *testing.T) {
+	if got := NewInstructionFromEnv("nomic-embed-text"); got.Document != "search_document: " {
+		t.Errorf("built-in document prefix = %q", got.Document)
+	}
+
+	t.Setenv("RAG_EMBED_QUERY_PREFIX", "Q: ")
+	t.Setenv("RAG_EMBED_DOCUMENT_PREFIX", "")
+	want := Instruction{Query: "Q: "}
+	if got := NewInstructionFromEnv("nomic-embed-text"); got != want {
+		t.Errorf("NewInstructionFromEnv = %+v, want %+v", got, want)
+	}
+}
+
+func TestInstructionEmbedderPrefixes(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeEmbedder()
+	embedder, err := NewInstructionEmbedder(inner, Instruction{Query: "query: ", Document: "passage: "})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := embedder.EmbedText(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embedder.EmbedTexts(ctx, []string{"b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embedder.EmbedQuery(ctx, "q"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := inner.embedded(), []string{"passage: a", "passage: b", "passage: c"}; !slices.Equal(got, want) {
+		t.Errorf("documents = %q, want %q", got, want)
+	}
+	if got, want := inner.queries, []string{"query: q"}; !slices.Equal(got, want) {
+		t.Errorf("queries = %q, want %q", got, want)
+	}
+	if embedder.GetModelName() != "fake" || embedder.GetDimension() != 2 {
+		t.Errorf("model = %q, dimension = %d, want the inner embedder's", embedder.GetModelName(), embedder.GetDimension())
+	}
+}
+
+func TestInstructionEmbedderQueryOnly(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeEmbedder()
+	embedder, err := NewInstructionEmbedder(inner, Instruction{Query: "query: "})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := embedder.EmbedTexts(ctx, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := inner.embedded(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("documents = %q, want them unchanged", got)
+	}
+	if _, err := NewInstructionEmbedder(nil, Instruction{}); err == nil {
+		t.Error("expected error for a nil inner embedder")
+	}
+}
+
+func TestInstructionEmbedderDocumentInstructor(t *testing.T) {
+	embedder, err := NewInstructionEmbedder(newFakeEmbedder(), Instruction{Query: "query: ", Document: "passage: "})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e Embedder = embedder
+	instructor, ok := e.(DocumentInstructor)
+	if !ok {
+		t.Fatal("InstructionEmbedder does not implement DocumentInstructor")
+	}
+	if got := instructor.DocumentInstruction(); got != "passage: " {
+		t.Errorf("DocumentInstruction() = %q, want %q", got, "passage: ")
+	}
+	if _, ok := Embedder(newFakeEmbedder()).(DocumentInstructor); ok {
+		t.Error("plain embedder should not implement DocumentInstructor")
+	}
+}
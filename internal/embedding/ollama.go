@@ -9,6 +9,9 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"goRag/internal/envconfig"
+	"goRag/internal/resilience"
 )
 
 // OllamaEmbedderConfig Ollama Embedding 配置
@@ -17,10 +20,12 @@ type OllamaEmbedderConfig struct {
 	Model     string        // 嵌入模型名称，如 "nomic-embed-text", "mxbai-embed-large" 等
 	Timeout   time.Duration // 请求超时时间
 	Dimension int           // 向量维度（如果已知，避免每次调用 API）
+
+	Resilience resilience.Config // 重试和熔断配置，零值表示不重试、不熔断
 }
 
-// NewOllamaEmbedderConfigFromEnv 从环境变量创建配置
-func NewOllamaEmbedderConfigFromEnv() *OllamaEmbedderConfig {
+// NewOllamaEmbedderConfigFromEnv 从环境变量创建配置，数值格式不正确时返回错误
+func NewOllamaEmbedderConfigFromEnv() (*OllamaEmbedderConfig, error) {
 	baseURL := os.Getenv("OLLAMA_BASE_URL")
 	if baseURL == "" {
 		baseURL = "http://localhost:11434"
@@ -31,33 +36,34 @@ func NewOllamaEmbedderConfigFromEnv() *OllamaEmbedderConfig {
 		model = "qwen3-embedding:0.6b" // 默认嵌入模型
 	}
 
-	timeout := 30 * time.Second
-	if timeoutStr := os.Getenv("OLLAMA_TIMEOUT"); timeoutStr != "" {
-		if parsed, err := time.ParseDuration(timeoutStr); err == nil {
-			timeout = parsed
-		}
+	timeout, err := envconfig.Duration("OLLAMA_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
 	}
-
-	// 尝试从环境变量获取维度，如果没有则设为 0（需要动态获取）
-	dimension := 0
-	if dimStr := os.Getenv("OLLAMA_EMBED_DIMENSION"); dimStr != "" {
-		fmt.Sscanf(dimStr, "%d", &dimension)
-	} else {
-		dimension = 768
+	// 设为 0 时启动时调用一次 API 获取维度
+	dimension, err := envconfig.Int("OLLAMA_EMBED_DIMENSION", 768)
+	if err != nil {
+		return nil, err
+	}
+	resilienceConfig, err := resilience.NewConfigFromEnv()
+	if err != nil {
+		return nil, err
 	}
 
 	return &OllamaEmbedderConfig{
-		BaseURL:   baseURL,
-		Model:     model,
-		Timeout:   timeout,
-		Dimension: dimension,
-	}
+		BaseURL:    baseURL,
+		Model:      model,
+		Timeout:    timeout,
+		Dimension:  dimension,
+		Resilience: resilienceConfig,
+	}, nil
 }
 
 // OllamaEmbedder Ollama 嵌入器实现
 type OllamaEmbedder struct {
 	config    *OllamaEmbedderConfig
 	client    *http.Client
+	transport *resilience.Transport
 	baseURL   string
 	model     string
 	dimension int // 缓存的维度
@@ -66,20 +72,26 @@ type OllamaEmbedder struct {
 // NewOllamaEmbedder 创建 Ollama 嵌入器
 func NewOllamaEmbedder(config *OllamaEmbedderConfig) (*OllamaEmbedder, error) {
 	if config == nil {
-		config = NewOllamaEmbedderConfigFromEnv()
+		var err error
+		if config, err = NewOllamaEmbedderConfigFromEnv(); err != nil {
+			return nil, err
+		}
 	}
 
 	if config.Model == "" {
 		return nil, fmt.Errorf("Ollama embedding model is required")
 	}
 
+	transport := resilience.NewTransport("ollama-embed", config.Resilience, nil)
 	client := &http.Client{
-		Timeout: config.Timeout,
+		Timeout:   config.Timeout,
+		Transport: transport,
 	}
 
 	embedder := &OllamaEmbedder{
 		config:    config,
 		client:    client,
+		transport: transport,
 		baseURL:   config.BaseURL,
 		model:     config.Model,
 		dimension: config.Dimension,
@@ -220,6 +232,12 @@ func (o *OllamaEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]fl
 	return o.embedBatch(ctx, texts)
 }
 
+// EmbedQuery 将检索查询转换为向量嵌入
+// Ollama 的接口本身不区分查询和文档，模型需要的指令前缀由 InstructionEmbedder 添加
+func (o *OllamaEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return o.EmbedText(ctx, query)
+}
+
 // GetDimension 返回嵌入向量的维度
 func (o *OllamaEmbedder) GetDimension() int {
 	if o == nil {
@@ -227,3 +245,16 @@ func (o *OllamaEmbedder) GetDimension() int {
 	}
 	return o.dimension
 }
+
+// GetModelName 返回嵌入模型名称
+func (o *OllamaEmbedder) GetModelName() string {
+	if o == nil {
+		return ""
+	}
+	return o.model
+}
+
+// Breaker 返回请求 Ollama 使用的熔断器
+func (o *OllamaEmbedder) Breaker() *resilience.Breaker {
+	return o.transport.Breaker()
+}
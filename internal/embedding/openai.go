@@ -0,0 +1,213 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"goRag/internal/envconfig"
+	"goRag/internal/resilience"
+)
+
+// openAIModelDimensions 常见 OpenAI 嵌入模型的默认维度
+var openAIModelDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+// OpenAIEmbedderConfig OpenAI Embedding 配置
+// BaseURL 可以指向任何 OpenAI 兼容的 /v1/embeddings 服务（如 Ollama 的 http://localhost:11434/v1、vLLM、LM Studio）
+type OpenAIEmbedderConfig struct {
+	APIKey       string        // 使用官方 API 时必填；设置了 BaseURL 时可以为空（本地服务通常不需要鉴权）
+	BaseURL      string        // 可选，用于自定义 API 端点
+	Organization string        // 可选，OpenAI 组织 ID
+	Model        string        // 嵌入模型名称，如 "text-embedding-3-small"
+	Dimensions   int           // 可选，请求的向量维度（dimensions 参数，仅 text-embedding-3 及以后的模型支持），0 表示使用模型默认维度
+	BatchSize    int           // 单个请求最多的文本条数，默认 256
+	Timeout      time.Duration // 可选，单次请求超时时间（包含重试），0 表示不限制
+
+	Resilience resilience.Config // 重试和熔断配置，零值表示不重试、不熔断
+}
+
+// NewOpenAIEmbedderConfigFromEnv 从环境变量创建配置，OPENAI_API_KEY 和 OPENAI_BASE_URL 都未设置时返回 nil，格式不正确时返回错误
+func NewOpenAIEmbedderConfigFromEnv() (*OpenAIEmbedderConfig, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if apiKey == "" && baseURL == "" {
+		return nil, nil
+	}
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+
+	model := os.Getenv("OPENAI_EMBED_MODEL")
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+
+	dimensions, err := envconfig.Int("OPENAI_EMBED_DIMENSIONS", 0)
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := envconfig.Int("OPENAI_EMBED_BATCH_SIZE", 256)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := envconfig.Duration("OPENAI_TIMEOUT", 0)
+	if err != nil {
+		return nil, err
+	}
+	resilienceConfig, err := resilience.NewConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpenAIEmbedderConfig{
+		APIKey:       apiKey,
+		BaseURL:      baseURL,
+		Organization: os.Getenv("OPENAI_ORGANIZATION"),
+		Model:        model,
+		Dimensions:   dimensions,
+		BatchSize:    batchSize,
+		Timeout:      timeout,
+		Resilience:   resilienceConfig,
+	}, nil
+}
+
+// OpenAIEmbedder 基于 Embeddings API 的嵌入器实现
+type OpenAIEmbedder struct {
+	config    *OpenAIEmbedderConfig
+	client    *openai.Client
+	transport *resilience.Transport
+	dimension int
+}
+
+// NewOpenAIEmbedder 创建 OpenAI 嵌入器
+// 维度依次取 Dimensions、已知模型的默认维度，都没有时调用一次 API 获取
+func NewOpenAIEmbedder(config *OpenAIEmbedderConfig) (*OpenAIEmbedder, error) {
+	if config == nil || (config.APIKey == "" && config.BaseURL == "") {
+		return nil, fmt.Errorf("OpenAI API key is required unless a custom base URL is set")
+	}
+	if config.Model == "" {
+		return nil, fmt.Errorf("OpenAI embedding model is required")
+	}
+	if config.Dimensions < 0 {
+		return nil, fmt.Errorf("embedding dimensions must be non-negative, got %d", config.Dimensions)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 256
+	}
+
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	}
+	clientConfig.OrgID = config.Organization
+	transport := resilience.NewTransport("openai-embed", config.Resilience, nil)
+	clientConfig.HTTPClient = &http.Client{
+		Timeout:   config.Timeout,
+		Transport: transport,
+	}
+
+	embedder := &OpenAIEmbedder{
+		config:    config,
+		client:    openai.NewClientWithConfig(clientConfig),
+		transport: transport,
+		dimension: config.Dimensions,
+	}
+
+	if embedder.dimension == 0 {
+		embedder.dimension = openAIModelDimensions[config.Model]
+	}
+	if embedder.dimension == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		vectors, err := embedder.embedBatch(ctx, []string{"test"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get embedding dimension: %w", err)
+		}
+		embedder.dimension = len(vectors[0])
+	}
+
+	return embedder, nil
+}
+
+// embedBatch 调用一次 Embeddings API，按返回的 index 还原输入顺序
+func (o *OpenAIEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := o.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input:          texts,
+		Model:          openai.EmbeddingModel(o.config.Model),
+		Dimensions:     o.config.Dimensions,
+		EncodingFormat: openai.EmbeddingEncodingFormatFloat,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("mismatched number of embeddings: expected %d, got %d", len(texts), len(resp.Data))
+	}
+
+	sort.Slice(resp.Data, func(i, j int) bool {
+		return resp.Data[i].Index < resp.Data[j].Index
+	})
+	results := make([][]float32, len(texts))
+	for i, data := range resp.Data {
+		if data.Index != i || len(data.Embedding) == 0 {
+			return nil, fmt.Errorf("invalid embedding at index %d", data.Index)
+		}
+		results[i] = data.Embedding
+	}
+	return results, nil
+}
+
+// EmbedText 将文本转换为向量嵌入
+func (o *OpenAIEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := o.embedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EmbedTexts 批量将文本转换为向量嵌入，超过 BatchSize 时拆成多个请求依次发送
+// 需要并发请求时用 BatchingEmbedder 包装。
+func (o *OpenAIEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	results := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += o.config.BatchSize {
+		end := min(start+o.config.BatchSize, len(texts))
+		vectors, err := o.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, vectors...)
+	}
+	return results, nil
+}
+
+// EmbedQuery 将检索查询转换为向量嵌入
+// Embeddings API 的接口本身不区分查询和文档，模型需要的指令前缀由 InstructionEmbedder 添加
+func (o *OpenAIEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return o.EmbedText(ctx, query)
+}
+
+// GetDimension 返回嵌入向量的维度
+func (o *OpenAIEmbedder) GetDimension() int {
+	return o.dimension
+}
+
+// GetModelName 返回嵌入模型名称
+func (o *OpenAIEmbedder) GetModelName() string {
+	return o.config.Model
+}
+
+// Breaker 返回请求 OpenAI 使用的熔断器
+func (o *OpenAIEmbedder) Breaker() *resilience.Breaker {
+	return o.transport.Breaker()
+}
@@ -0,0 +1,201 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeEmbeddingsServer 模拟 /v1/embeddings 接口
+// 输入 "t<n>" 的向量为 [n, n]；respond 可以改写返回的数据（如打乱顺序、少返回）
+type fakeEmbeddingsServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	auth     []string
+}
+
+type fakeEmbeddingData struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+func newFakeEmbeddingsServer(t *testing.T, respond func(data []fakeEmbeddingData) []fakeEmbeddingData) *fakeEmbeddingsServer {
+	t.Helper()
+	s := &fakeEmbeddingsServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, body)
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		s.mu.Unlock()
+
+		inputs, _ := body["input"].([]interface{})
+		data := make([]fakeEmbeddingData, len(inputs))
+		for i, input := range inputs {
+			n, _ := strconv.Atoi(strings.TrimPrefix(input.(string), "t"))
+			data[i] = fakeEmbeddingData{Object: "embedding", Index: i, Embedding: []float32{float32(n), float32(n)}}
+		}
+		if respond != nil {
+			data = respond(data)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"data":   data,
+			"model":  body["model"],
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestOpenAIEmbedder(t *testing.T, server *fakeEmbeddingsServer, config OpenAIEmbedderConfig) *OpenAIEmbedder {
+	t.Helper()
+	config.BaseURL = server.URL + "/v1"
+	if config.Model == "" {
+		config.Model = "text-embedding-3-small"
+	}
+	embedder, err := NewOpenAIEmbedder(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return embedder
+}
+
+func TestOpenAIEmbedderRestoresOrderByIndex(t *testing.T) {
+	server := newFakeEmbeddingsServer(t, func(data []fakeEmbeddingData) []fakeEmbeddingData {
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		return data
+	})
+	embedder := newTestOpenAIEmbedder(t, server, OpenAIEmbedderConfig{APIKey: "sk-test"})
+
+	vectors, err := embedder.EmbedTexts(context.Background(), []string{"t0", "t1", "t2", "t3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, vector := range vectors {
+		if vector[0] != float32(i) {
+			t.Errorf("vector %d = %v, want [%d %d]", i, vector, i, i)
+		}
+	}
+}
+
+func TestOpenAIEmbedderDimensionsParam(t *testing.T) {
+	tests := []struct {
+		name       string
+		dimensions int
+		want       interface{} // nil 表示请求中不带 dimensions
+		dimension  int
+	}{
+		{"default", 0, nil, 1536},
+		{"explicit", 256, float64(256), 256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeEmbeddingsServer(t, nil)
+			embedder := newTestOpenAIEmbedder(t, server, OpenAIEmbedderConfig{APIKey: "sk-test", Dimensions: tt.dimensions})
+
+			if _, err := embedder.EmbedText(context.Background(), "t1"); err != nil {
+				t.Fatal(err)
+			}
+			if got := server.requests[0]["dimensions"]; got != tt.want {
+				t.Errorf("dimensions = %v, want %v", got, tt.want)
+			}
+			if embedder.GetDimension() != tt.dimension {
+				t.Errorf("GetDimension() = %d, want %d", embedder.GetDimension(), tt.dimension)
+			}
+		})
+	}
+}
+
+func TestOpenAIEmbedderSplitsByBatchSize(t *testing.T) {
+	server := newFakeEmbeddingsServer(t, nil)
+	embedder := newTestOpenAIEmbedder(t, server, OpenAIEmbedderConfig{APIKey: "sk-test", BatchSize: 2})
+
+	texts := []string{"t0", "t1", "t2", "t3", "t4"}
+	vectors, err := embedder.EmbedTexts(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != len(texts) {
+		t.Fatalf("got %d vectors, want %d", len(vectors), len(texts))
+	}
+	for i, vector := range vectors {
+		if vector[0] != float32(i) {
+			t.Errorf("vector %d = %v, want [%d %d]", i, vector, i, i)
+		}
+	}
+
+	var sizes []int
+	for _, req := range server.requests {
+		sizes = append(sizes, len(req["input"].([]interface{})))
+	}
+	if want := []int{2, 2, 1}; !slices.Equal(sizes, want) {
+		t.Errorf("request sizes = %v, want %v", sizes, want)
+	}
+}
+
+func TestOpenAIEmbedderCountMismatch(t *testing.T) {
+	server := newFakeEmbeddingsServer(t, func(data []fakeEmbeddingData) []fakeEmbeddingData {
+		return data[:len(data)-1]
+	})
+	embedder := newTestOpenAIEmbedder(t, server, OpenAIEmbedderConfig{APIKey: "sk-test"})
+
+	_, err := embedder.EmbedTexts(context.Background(), []string{"t0", "t1", "t2"})
+	if err == nil || !strings.Contains(err.Error(), "mismatched number of embeddings") {
+		t.Fatalf("expected mismatched count error, got %v", err)
+	}
+}
+
+func TestOpenAIEmbedderWithoutAPIKey(t *testing.T) {
+	if _, err := NewOpenAIEmbedder(&OpenAIEmbedderConfig{Model: "text-embedding-3-small"}); err == nil {
+		t.Error("expected error without API key and base URL")
+	}
+
+	server := newFakeEmbeddingsServer(t, nil)
+	embedder := newTestOpenAIEmbedder(t, server, OpenAIEmbedderConfig{})
+	if _, err := embedder.EmbedText(context.Background(), "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if server.auth[0] != "" {
+		t.Errorf("Authorization = %q, want empty", server.auth[0])
+	}
+}
+
+func TestNewOpenAIEmbedderConfigFromEnv(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_BASE_URL", "")
+	if config, err := NewOpenAIEmbedderConfigFromEnv(); config != nil || err != nil {
+		t.Errorf("expected nil config without key and base URL, got %+v, %v", config, err)
+	}
+
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1")
+	config, err := NewOpenAIEmbedderConfigFromEnv()
+	if err != nil || config == nil || config.BaseURL != "http://localhost:11434/v1" {
+		t.Errorf("expected config for keyless base URL, got %+v, %v", config, err)
+	}
+
+	t.Setenv("OPENAI_EMBED_BATCH_SIZE", "many")
+	if _, err := NewOpenAIEmbedderConfigFromEnv(); err == nil {
+		t.Error("expected an error for a malformed OPENAI_EMBED_BATCH_SIZE")
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"crypto/md5"
 	"math"
-	"strings"
-	"unicode"
+
+	"goRag/internal/tokenizer"
 )
 
 // SimpleEmbedder 简单的内存嵌入器实现
@@ -14,25 +14,32 @@ type SimpleEmbedder struct {
 	dimension int
 	vocab     map[string]int
 	vocabSize int
+	tokenizer tokenizer.Tokenizer
 }
 
 // NewSimpleEmbedder 创建简单嵌入器
 // dimension: 向量维度
+// 默认使用单字 + 双字的中文切分，中文句子不会再被当作一个"词"
 func NewSimpleEmbedder(dimension int) *SimpleEmbedder {
+	return NewSimpleEmbedderWithTokenizer(dimension, tokenizer.NewCJKTokenizer(tokenizer.Config{
+		CJKMode:   tokenizer.ModeUnigramBigram,
+		StopWords: tokenizer.DefaultStopWords(),
+	}))
+}
+
+// NewSimpleEmbedderWithTokenizer 使用指定分词器创建简单嵌入器
+func NewSimpleEmbedderWithTokenizer(dimension int, tok tokenizer.Tokenizer) *SimpleEmbedder {
 	return &SimpleEmbedder{
 		dimension: dimension,
 		vocab:     make(map[string]int),
 		vocabSize: 0,
+		tokenizer: tok,
 	}
 }
 
-// tokenize 分词（简单实现）
+// tokenize 分词
 func (e *SimpleEmbedder) tokenize(text string) []string {
-	text = strings.ToLower(text)
-	words := strings.FieldsFunc(text, func(c rune) bool {
-		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
-	})
-	return words
+	return e.tokenizer.Tokenize(text)
 }
 
 // hashWord 将单词哈希到向量维度
@@ -46,7 +53,7 @@ func (e *SimpleEmbedder) hashWord(word string) int {
 func (e *SimpleEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
 	vector := make([]float32, e.dimension)
 	words := e.tokenize(text)
-	
+
 	if len(words) == 0 {
 		return vector, nil
 	}
@@ -101,8 +108,18 @@ func (e *SimpleEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]fl
 	return results, nil
 }
 
+// EmbedQuery 将检索查询转换为向量嵌入，与文档使用相同的方法
+func (e *SimpleEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return e.EmbedText(ctx, query)
+}
+
 // GetDimension 返回嵌入向量的维度
 func (e *SimpleEmbedder) GetDimension() int {
 	return e.dimension
 }
 
+// GetModelName 返回嵌入模型名称
+// v2 起改用 CJK 分词，生成的向量与旧版不兼容
+func (e *SimpleEmbedder) GetModelName() string {
+	return "simple-hash-v2"
+}
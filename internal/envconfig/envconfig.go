@@ -0,0 +1,58 @@
+// Package envconfig 读取数值类环境变量
+// 格式不正确时返回错误，避免拼写错误被静默当作 0 或默认值；是否退出进程由调用方决定。
+package envconfig
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// LookupInt 读取整数环境变量，未设置（或为空）时返回 false
+func LookupInt(name string) (int, bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s=%q: expected an integer", name, v)
+	}
+	return n, true, nil
+}
+
+// Int 读取整数环境变量，未设置时返回 def
+func Int(name string, def int) (int, error) {
+	n, ok, err := LookupInt(name)
+	if err != nil || !ok {
+		return def, err
+	}
+	return n, nil
+}
+
+// Float 读取浮点数环境变量，未设置时返回 def
+func Float(name string, def float64) (float64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s=%q: expected a number", name, v)
+	}
+	return f, nil
+}
+
+// Duration 读取时长环境变量（如 "30s"、"500ms"），未设置时返回 def
+func Duration(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s=%q: expected a duration such as 30s", name, v)
+	}
+	return d, nil
+}
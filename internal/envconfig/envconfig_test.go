@@ -0,0 +1,58 @@
+package envconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+		wantErr  bool
+	}{
+		{"", 7, false},
+		{"42", 42, false},
+		{"-1", -1, false},
+		{"4x", 7, true},
+		{"1.5", 7, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("ENVCONFIG_TEST", tt.value)
+		got, err := Int("ENVCONFIG_TEST", 7)
+		if (err != nil) != tt.wantErr || got != tt.expected {
+			t.Errorf("Int(%q) = %d, %v; want %d, error %v", tt.value, got, err, tt.expected, tt.wantErr)
+		}
+	}
+}
+
+func TestLookupInt(t *testing.T) {
+	t.Setenv("ENVCONFIG_TEST", "")
+	if _, ok, err := LookupInt("ENVCONFIG_TEST"); ok || err != nil {
+		t.Errorf("unset variable: ok = %v, err = %v", ok, err)
+	}
+	t.Setenv("ENVCONFIG_TEST", "0")
+	if n, ok, err := LookupInt("ENVCONFIG_TEST"); !ok || err != nil || n != 0 {
+		t.Errorf("LookupInt(\"0\") = %d, %v, %v; want 0, true, nil", n, ok, err)
+	}
+}
+
+func TestFloatAndDuration(t *testing.T) {
+	t.Setenv("ENVCONFIG_TEST", "0.5")
+	if f, err := Float("ENVCONFIG_TEST", 1); err != nil || f != 0.5 {
+		t.Errorf("Float = %v, %v; want 0.5", f, err)
+	}
+	t.Setenv("ENVCONFIG_TEST", "half")
+	if _, err := Float("ENVCONFIG_TEST", 1); err == nil {
+		t.Error("Float should reject a non-numeric value")
+	}
+
+	t.Setenv("ENVCONFIG_TEST", "250ms")
+	if d, err := Duration("ENVCONFIG_TEST", time.Second); err != nil || d != 250*time.Millisecond {
+		t.Errorf("Duration = %v, %v; want 250ms", d, err)
+	}
+	t.Setenv("ENVCONFIG_TEST", "30")
+	if d, err := Duration("ENVCONFIG_TEST", time.Second); err == nil || d != time.Second {
+		t.Errorf("Duration without unit = %v, %v; want an error and the default", d, err)
+	}
+}
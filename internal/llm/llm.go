@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"fmt"
 )
 
 // Message 消息结构
@@ -10,13 +11,71 @@ type Message struct {
 	Content string
 }
 
+// 未指定生成参数时使用的默认值
+const (
+	DefaultTemperature = 0.7
+	DefaultMaxTokens   = 1000
+)
+
+// GenerateOptions 单次生成的参数，零值表示使用实现的默认值
+type GenerateOptions struct {
+	Temperature *float64 // 采样温度，nil 表示 DefaultTemperature；设为 0 可得到（近似）确定的输出
+	TopP        *float64 // 核采样概率阈值，nil 表示使用服务默认值
+	MaxTokens   int      // 最多生成的 token 数，0 表示 DefaultMaxTokens
+	Stop        []string // 停止序列
+	Seed        *int     // 随机种子，配合 Temperature 获得可复现的输出
+	Model       string   // 覆盖配置中的模型名称
+	JSONMode    bool     // 要求模型输出 JSON 对象
+}
+
+// Validate 校验生成参数
+func (o GenerateOptions) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("temperature must be in [0, 2], got %v", *o.Temperature)
+	}
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		return fmt.Errorf("top_p must be in (0, 1], got %v", *o.TopP)
+	}
+	if o.MaxTokens < 0 {
+		return fmt.Errorf("max tokens cannot be negative, got %d", o.MaxTokens)
+	}
+	return nil
+}
+
+// temperature 返回实际使用的采样温度
+func (o GenerateOptions) temperature() float64 {
+	if o.Temperature == nil {
+		return DefaultTemperature
+	}
+	return *o.Temperature
+}
+
+// maxTokens 返回实际使用的最大生成 token 数
+func (o GenerateOptions) maxTokens() int {
+	if o.MaxTokens <= 0 {
+		return DefaultMaxTokens
+	}
+	return o.MaxTokens
+}
+
+// model 返回实际使用的模型名称
+func (o GenerateOptions) model(fallback string) string {
+	if o.Model == "" {
+		return fallback
+	}
+	return o.Model
+}
+
 // LLM 大语言模型接口
 type LLM interface {
 	// Generate 生成回复
-	Generate(ctx context.Context, messages []Message) (string, error)
+	Generate(ctx context.Context, messages []Message, opts GenerateOptions) (string, error)
 
 	// GenerateStream 流式生成回复
-	GenerateStream(ctx context.Context, messages []Message, callback func(string) error) error
+	GenerateStream(ctx context.Context, messages []Message, opts GenerateOptions, callback func(string) error) error
+
+	// GetModelName 获取默认使用的模型名称
+	GetModelName() string
 }
 
 // Service LLM 服务
@@ -32,11 +91,22 @@ func NewService(llm LLM) *Service {
 }
 
 // Generate 生成回复
-func (s *Service) Generate(ctx context.Context, messages []Message) (string, error) {
-	return s.llm.Generate(ctx, messages)
+func (s *Service) Generate(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	return s.llm.Generate(ctx, messages, opts)
+}
+
+// GetModelName 获取默认使用的模型名称
+func (s *Service) GetModelName() string {
+	return s.llm.GetModelName()
 }
 
 // GenerateStream 流式生成回复
-func (s *Service) GenerateStream(ctx context.Context, messages []Message, callback func(string) error) error {
-	return s.llm.GenerateStream(ctx, messages, callback)
+func (s *Service) GenerateStream(ctx context.Context, messages []Message, opts GenerateOptions, callback func(string) error) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	return s.llm.GenerateStream(ctx, messages, opts, callback)
 }
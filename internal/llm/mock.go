@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -19,16 +20,16 @@ func NewMockLLM() *MockLLM {
 }
 
 // Generate 生成回复
-func (m *MockLLM) Generate(ctx context.Context, messages []Message) (string, error) {
+func (m *MockLLM) Generate(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
 	if len(messages) == 0 {
 		return "", fmt.Errorf("no messages provided")
 	}
 
-	// 提取用户消息内容
+	// 提取最后一条用户消息内容（之前可能有少样本示例和历史消息）
 	var userContent string
-	for _, msg := range messages {
-		if msg.Role == "user" {
-			userContent = msg.Content
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			userContent = messages[i].Content
 			break
 		}
 	}
@@ -39,15 +40,23 @@ func (m *MockLLM) Generate(ctx context.Context, messages []Message) (string, err
 	}
 
 	// 简单的模拟回复
-	response := fmt.Sprintf("基于提供的信息，我理解您的问题。相关内容已包含在上下文中。这是一个模拟回复。\n\n原始查询: %s", 
+	response := fmt.Sprintf("基于提供的信息，我理解您的问题。相关内容已包含在上下文中。这是一个模拟回复。\n\n原始查询: %s",
 		extractQuery(userContent))
-	
+
+	if opts.JSONMode {
+		data, err := json.Marshal(map[string]string{"response": response})
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
 	return response, nil
 }
 
 // GenerateStream 流式生成回复
-func (m *MockLLM) GenerateStream(ctx context.Context, messages []Message, callback func(string) error) error {
-	response, err := m.Generate(ctx, messages)
+func (m *MockLLM) GenerateStream(ctx context.Context, messages []Message, opts GenerateOptions, callback func(string) error) error {
+	response, err := m.Generate(ctx, messages, opts)
 	if err != nil {
 		return err
 	}
@@ -77,3 +86,7 @@ func extractQuery(prompt string) string {
 	return prompt
 }
 
+// GetModelName 获取模型名称
+func (m *MockLLM) GetModelName() string {
+	return m.name
+}
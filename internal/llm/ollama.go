@@ -9,17 +9,22 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"goRag/internal/envconfig"
+	"goRag/internal/resilience"
 )
 
 // OllamaConfig Ollama 配置
 type OllamaConfig struct {
 	BaseURL string        // Ollama 服务地址，默认 http://localhost:11434
 	Model   string        // 模型名称，如 "llama2", "qwen2.5:3b" 等
-	Timeout time.Duration // 请求超时时间
+	Timeout time.Duration // 请求超时时间（包含重试）
+
+	Resilience resilience.Config // 重试和熔断配置，零值表示不重试、不熔断
 }
 
-// NewOllamaConfigFromEnv 从环境变量创建配置
-func NewOllamaConfigFromEnv() *OllamaConfig {
+// NewOllamaConfigFromEnv 从环境变量创建配置，数值格式不正确时返回错误
+func NewOllamaConfigFromEnv() (*OllamaConfig, error) {
 	baseURL := os.Getenv("OLLAMA_BASE_URL")
 	if baseURL == "" {
 		baseURL = "http://localhost:11434"
@@ -30,58 +35,111 @@ func NewOllamaConfigFromEnv() *OllamaConfig {
 		model = "qwen2.5:3b-instruct" // 默认模型
 	}
 
-	timeout := 30 * time.Second
-	if timeoutStr := os.Getenv("OLLAMA_TIMEOUT"); timeoutStr != "" {
-		if parsed, err := time.ParseDuration(timeoutStr); err == nil {
-			timeout = parsed
-		}
+	timeout, err := envconfig.Duration("OLLAMA_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	resilienceConfig, err := resilience.NewConfigFromEnv()
+	if err != nil {
+		return nil, err
 	}
 
 	return &OllamaConfig{
-		BaseURL: baseURL,
-		Model:   model,
-		Timeout: timeout,
-	}
+		BaseURL:    baseURL,
+		Model:      model,
+		Timeout:    timeout,
+		Resilience: resilienceConfig,
+	}, nil
 }
 
 // Ollama LLM 实现
 type Ollama struct {
-	config  *OllamaConfig
-	client  *http.Client
-	baseURL string
-	model   string
+	config    *OllamaConfig
+	client    *http.Client
+	transport *resilience.Transport
+	baseURL   string
+	model     string
 }
 
 // NewOllama 创建 Ollama LLM
 func NewOllama(config *OllamaConfig) (*Ollama, error) {
 	if config == nil {
-		config = NewOllamaConfigFromEnv()
+		var err error
+		if config, err = NewOllamaConfigFromEnv(); err != nil {
+			return nil, err
+		}
 	}
 
 	if config.Model == "" {
 		return nil, fmt.Errorf("Ollama model is required")
 	}
 
+	transport := resilience.NewTransport("ollama-llm", config.Resilience, nil)
 	client := &http.Client{
-		Timeout: config.Timeout,
+		Timeout:   config.Timeout,
+		Transport: transport,
 	}
 
 	return &Ollama{
-		config:  config,
-		client:  client,
-		baseURL: config.BaseURL,
-		model:   config.Model,
+		config:    config,
+		client:    client,
+		transport: transport,
+		baseURL:   config.BaseURL,
+		model:     config.Model,
 	}, nil
 }
 
+// Breaker 返回请求 Ollama 使用的熔断器
+func (o *Ollama) Breaker() *resilience.Breaker {
+	return o.transport.Breaker()
+}
+
 // ollamaChatRequest Ollama API 请求结构
 type ollamaChatRequest struct {
 	Model    string                 `json:"model"`
 	Messages []ollamaMessage        `json:"messages"`
 	Stream   bool                   `json:"stream"`
+	Format   string                 `json:"format,omitempty"`
 	Options  map[string]interface{} `json:"options,omitempty"`
 }
 
+// newChatRequest 根据生成参数构建请求
+func (o *Ollama) newChatRequest(messages []Message, opts GenerateOptions, stream bool) ollamaChatRequest {
+	// 转换消息格式
+	ollamaMessages := make([]ollamaMessage, len(messages))
+	for i, msg := range messages {
+		ollamaMessages[i] = ollamaMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+	}
+
+	options := map[string]interface{}{
+		"temperature": opts.temperature(),
+		"num_predict": opts.maxTokens(),
+	}
+	if opts.TopP != nil {
+		options["top_p"] = *opts.TopP
+	}
+	if len(opts.Stop) > 0 {
+		options["stop"] = opts.Stop
+	}
+	if opts.Seed != nil {
+		options["seed"] = *opts.Seed
+	}
+
+	req := ollamaChatRequest{
+		Model:    opts.model(o.model),
+		Messages: ollamaMessages,
+		Stream:   stream,
+		Options:  options,
+	}
+	if opts.JSONMode {
+		req.Format = "json"
+	}
+	return req
+}
+
 // ollamaMessage Ollama 消息结构
 type ollamaMessage struct {
 	Role    string `json:"role"`
@@ -99,7 +157,7 @@ type ollamaChatResponse struct {
 }
 
 // Generate 生成回复
-func (o *Ollama) Generate(ctx context.Context, messages []Message) (string, error) {
+func (o *Ollama) Generate(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
 	if o == nil {
 		return "", fmt.Errorf("Ollama client is nil")
 	}
@@ -110,24 +168,8 @@ func (o *Ollama) Generate(ctx context.Context, messages []Message) (string, erro
 		return "", fmt.Errorf("Ollama HTTP client is not initialized")
 	}
 
-	// 转换消息格式
-	ollamaMessages := make([]ollamaMessage, len(messages))
-	for i, msg := range messages {
-		ollamaMessages[i] = ollamaMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
-	}
-
 	// 构建请求
-	reqBody := ollamaChatRequest{
-		Model:    o.model,
-		Messages: ollamaMessages,
-		Stream:   false,
-		Options: map[string]interface{}{
-			"temperature": 0.7,
-		},
-	}
+	reqBody := o.newChatRequest(messages, opts, false)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -170,7 +212,7 @@ func (o *Ollama) Generate(ctx context.Context, messages []Message) (string, erro
 }
 
 // GenerateStream 流式生成回复
-func (o *Ollama) GenerateStream(ctx context.Context, messages []Message, callback func(string) error) error {
+func (o *Ollama) GenerateStream(ctx context.Context, messages []Message, opts GenerateOptions, callback func(string) error) error {
 	if o == nil {
 		return fmt.Errorf("Ollama client is nil")
 	}
@@ -181,24 +223,8 @@ func (o *Ollama) GenerateStream(ctx context.Context, messages []Message, callbac
 		return fmt.Errorf("Ollama HTTP client is not initialized")
 	}
 
-	// 转换消息格式
-	ollamaMessages := make([]ollamaMessage, len(messages))
-	for i, msg := range messages {
-		ollamaMessages[i] = ollamaMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
-	}
-
 	// 构建请求（流式）
-	reqBody := ollamaChatRequest{
-		Model:    o.model,
-		Messages: ollamaMessages,
-		Stream:   true,
-		Options: map[string]interface{}{
-			"temperature": 0.7,
-		},
-	}
+	reqBody := o.newChatRequest(messages, opts, true)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -257,3 +283,8 @@ func (o *Ollama) GenerateStream(ctx context.Context, messages []Message, callbac
 
 	return nil
 }
+
+// GetModelName 获取模型名称
+func (o *Ollama) GetModelName() string {
+	return o.model
+}
@@ -2,71 +2,147 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
-)
 
-// OpenAI LLM 实现（需要 go-openai 库）
-// 这是一个接口定义，实际使用时需要安装依赖并实现
+	"goRag/internal/envconfig"
+	"goRag/internal/resilience"
+)
 
 // OpenAIConfig OpenAI 配置
+// BaseURL 可以指向任何 OpenAI 兼容服务（如 Ollama 的 http://localhost:11434/v1、vLLM、LM Studio）
 type OpenAIConfig struct {
-	APIKey  string
-	Model   string
-	BaseURL string // 可选，用于自定义 API 端点
+	APIKey       string            // 使用官方 API 时必填；设置了 BaseURL 时可以为空（本地服务通常不需要鉴权）
+	Model        string            // 模型名称，如 "gpt-4o-mini" 或本地服务中的模型名
+	BaseURL      string            // 可选，用于自定义 API 端点
+	Organization string            // 可选，OpenAI 组织 ID
+	Headers      map[string]string // 可选，每个请求附加的自定义请求头（如网关鉴权）
+	Timeout      time.Duration     // 可选，单次请求超时时间（包含重试），0 表示不限制
+
+	Resilience resilience.Config // 重试和熔断配置，零值表示不重试、不熔断
 }
 
-// NewOpenAIConfigFromEnv 从环境变量创建配置
-func NewOpenAIConfigFromEnv() *OpenAIConfig {
+// NewOpenAIConfigFromEnv 从环境变量创建配置，OPENAI_API_KEY 和 OPENAI_BASE_URL 都未设置时返回 nil，数值格式不正确时返回错误
+func NewOpenAIConfigFromEnv() (*OpenAIConfig, error) {
 	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if apiKey == "" && baseURL == "" {
+		return nil, nil
 	}
 
 	model := os.Getenv("OPENAI_MODEL")
 	if model == "" {
 		model = "qwen2.5:3b-instruct"
 	}
-	baseURL := os.Getenv("OPENAI_BASE_URL")
 	if baseURL == "" {
 		baseURL = "https://api.openai.com/v1"
 		// baseURL = "http://localhost:11434/v1"
 	}
 
+	timeout, err := envconfig.Duration("OPENAI_TIMEOUT", 0)
+	if err != nil {
+		return nil, err
+	}
+	resilienceConfig, err := resilience.NewConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
 	return &OpenAIConfig{
-		APIKey:  apiKey,
-		Model:   model,
-		BaseURL: baseURL,
+		APIKey:       apiKey,
+		Model:        model,
+		BaseURL:      baseURL,
+		Organization: os.Getenv("OPENAI_ORGANIZATION"),
+		Headers:      parseHeaders(os.Getenv("OPENAI_HEADERS")),
+		Timeout:      timeout,
+		Resilience:   resilienceConfig,
+	}, nil
+}
+
+// parseHeaders 解析 "Name1=Value1,Name2=Value2" 格式的请求头列表，忽略格式不正确的项
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		name, val, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		headers[name] = strings.TrimSpace(val)
 	}
+	return headers
 }
 
-// OpenAI LLM 实现占位符
-// 实际使用时需要安装: go get github.com/sashabaranov/go-openai
+// OpenAI 基于 Chat Completions API 的 LLM 实现
 type OpenAI struct {
-	config *OpenAIConfig
-	client *openai.Client
+	config    *OpenAIConfig
+	client    *openai.Client
+	transport *resilience.Transport
 }
 
-// NewOpenAI 创建 OpenAI LLM（需要实现）
+// NewOpenAI 创建 OpenAI LLM
 func NewOpenAI(config *OpenAIConfig) (*OpenAI, error) {
-	if config == nil || config.APIKey == "" {
-		return nil, fmt.Errorf("OpenAI API key is required")
+	if config == nil || (config.APIKey == "" && config.BaseURL == "") {
+		return nil, fmt.Errorf("OpenAI API key is required unless a custom base URL is set")
+	}
+	if config.Model == "" {
+		return nil, fmt.Errorf("OpenAI model is required")
 	}
 
-	// TODO: 实现 OpenAI 客户端初始化
-	client := openai.NewClient(config.APIKey)
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	}
+	clientConfig.OrgID = config.Organization
+	transport := resilience.NewTransport("openai", config.Resilience, &headerTransport{
+		headers: config.Headers,
+		base:    http.DefaultTransport,
+	})
+	clientConfig.HTTPClient = &http.Client{
+		Timeout:   config.Timeout,
+		Transport: transport,
+	}
 
 	return &OpenAI{
-		config: config,
-		client: client,
+		config:    config,
+		client:    openai.NewClientWithConfig(clientConfig),
+		transport: transport,
 	}, nil
+}
+
+// Breaker 返回请求 OpenAI 使用的熔断器
+func (o *OpenAI) Breaker() *resilience.Breaker {
+	return o.transport.Breaker()
+}
+
+// headerTransport 为每个请求附加自定义请求头
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
 
+// RoundTrip 实现 http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) > 0 {
+		// RoundTripper 不应修改原请求
+		req = req.Clone(req.Context())
+		for name, value := range t.headers {
+			req.Header.Set(name, value)
+		}
+	}
+	return t.base.RoundTrip(req)
 }
 
-// Generate 生成回复（需要实现）
-func (o *OpenAI) Generate(ctx context.Context, messages []Message) (string, error) {
+// Generate 生成回复
+func (o *OpenAI) Generate(ctx context.Context, messages []Message, opts GenerateOptions) (string, error) {
 	// 检查配置和客户端是否初始化
 	if o == nil {
 		return "", fmt.Errorf("OpenAI client is nil")
@@ -78,28 +154,45 @@ func (o *OpenAI) Generate(ctx context.Context, messages []Message) (string, erro
 		return "", fmt.Errorf("OpenAI client is not initialized")
 	}
 
-	// TODO: 实现 OpenAI API 调用
-	// 示例代码结构:
+	req := o.newChatRequest(messages, opts, false)
+	resp, err := o.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI API returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// newChatRequest 根据生成参数构建请求
+func (o *OpenAI) newChatRequest(messages []Message, opts GenerateOptions, stream bool) openai.ChatCompletionRequest {
 	req := openai.ChatCompletionRequest{
-		Model:               o.config.Model,
+		Model:               opts.model(o.config.Model),
 		Messages:            convertMessages(messages),
-		MaxCompletionTokens: 1000,
-		Temperature:         0.7,
-		TopP:                1,
+		MaxCompletionTokens: opts.maxTokens(),
+		Temperature:         float32(opts.temperature()),
 		N:                   1,
-		Stream:              false,
-		Stop:                []string{},
-		PresencePenalty:     0,
-		FrequencyPenalty:    0,
-		ResponseFormat:      nil,
-		Seed:                nil,
+		Stream:              stream,
+		Stop:                opts.Stop,
+		Seed:                opts.Seed,
 	}
-	resp, err := o.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return "", err
+	// go-openai 会省略零值的 temperature（服务端按 1 处理），用最小正数表示 0
+	if req.Temperature == 0 {
+		req.Temperature = math.SmallestNonzeroFloat32
 	}
-	return resp.Choices[0].Message.Content, nil
+	if opts.TopP != nil {
+		req.TopP = float32(*opts.TopP)
+	}
+	if opts.JSONMode {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+	return req
 }
+
+// convertMessages 转换为 go-openai 消息格式
 func convertMessages(messages []Message) []openai.ChatCompletionMessage {
 	converted := make([]openai.ChatCompletionMessage, len(messages))
 	for i, message := range messages {
@@ -111,8 +204,50 @@ func convertMessages(messages []Message) []openai.ChatCompletionMessage {
 	return converted
 }
 
-// GenerateStream 流式生成回复（需要实现）
-func (o *OpenAI) GenerateStream(ctx context.Context, messages []Message, callback func(string) error) error {
-	// TODO: 实现流式调用
-	return fmt.Errorf("not implemented")
+// GenerateStream 流式生成回复
+func (o *OpenAI) GenerateStream(ctx context.Context, messages []Message, opts GenerateOptions, callback func(string) error) error {
+	if o == nil {
+		return fmt.Errorf("OpenAI client is nil")
+	}
+	if o.config == nil {
+		return fmt.Errorf("OpenAI config is nil")
+	}
+	if o.client == nil {
+		return fmt.Errorf("OpenAI client is not initialized")
+	}
+
+	req := o.newChatRequest(messages, opts, true)
+	stream, err := o.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to create chat completion stream: %w", err)
+	}
+	defer stream.Close()
+
+	// 流式读取响应
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to receive stream response: %w", err)
+		}
+
+		// 部分服务会发送不含 choices 的片段（如最后的 usage 统计），直接跳过
+		if len(resp.Choices) == 0 {
+			continue
+		}
+
+		// 调用回调函数处理每个片段
+		if delta := resp.Choices[0].Delta.Content; delta != "" {
+			if err := callback(delta); err != nil {
+				return fmt.Errorf("callback error: %w", err)
+			}
+		}
+	}
+}
+
+// GetModelName 获取模型名称
+func (o *OpenAI) GetModelName() string {
+	return o.config.Model
 }
@@ -0,0 +1,261 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeChatServer 模拟 /v1/chat/completions 接口
+// 非流式请求返回 completion；流式请求按 SSE 依次发送 chunks，最后发送 [DONE]
+type fakeChatServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	headers  []http.Header
+}
+
+func newFakeChatServer(t *testing.T, completion map[string]interface{}, chunks []map[string]interface{}) *fakeChatServer {
+	t.Helper()
+	s := &fakeChatServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, body)
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+
+		if stream, _ := body["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range chunks {
+				data, _ := json.Marshal(chunk)
+				fmt.Fprintf(w, "data: %s\n\n", data)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(completion)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// chatCompletion 构造只有一个选项的非流式响应
+func chatCompletion(content string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     "chatcmpl-test",
+		"object": "chat.completion",
+		"choices": []map[string]interface{}{
+			{"index": 0, "message": map[string]string{"role": "assistant", "content": content}, "finish_reason": "stop"},
+		},
+	}
+}
+
+// deltaChunk 构造一个流式片段，content 为空时不带 choices（如最后的 usage 统计）
+func deltaChunk(content string) map[string]interface{} {
+	chunk := map[string]interface{}{"id": "chatcmpl-test", "object": "chat.completion.chunk"}
+	if content != "" {
+		chunk["choices"] = []map[string]interface{}{
+			{"index": 0, "delta": map[string]string{"content": content}},
+		}
+	} else {
+		chunk["choices"] = []map[string]interface{}{}
+		chunk["usage"] = map[string]int{"prompt_tokens": 1, "completion_tokens": 2, "total_tokens": 3}
+	}
+	return chunk
+}
+
+func newTestOpenAI(t *testing.T, server *fakeChatServer, config OpenAIConfig) *OpenAI {
+	t.Helper()
+	config.BaseURL = server.URL + "/v1"
+	if config.Model == "" {
+		config.Model = "gpt-test"
+	}
+	model, err := NewOpenAI(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+var testMessages = []Message{{Role: "user", Content: "hello"}}
+
+func TestOpenAIGenerate(t *testing.T) {
+	server := newFakeChatServer(t, chatCompletion("hi there"), nil)
+	model := newTestOpenAI(t, server, OpenAIConfig{APIKey: "sk-test"})
+
+	reply, err := model.Generate(context.Background(), testMessages, GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hi there" {
+		t.Errorf("reply = %q, want %q", reply, "hi there")
+	}
+	if got := server.headers[0].Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer sk-test")
+	}
+}
+
+func TestOpenAIGenerateNoChoices(t *testing.T) {
+	server := newFakeChatServer(t, map[string]interface{}{"id": "chatcmpl-test", "choices": []interface{}{}}, nil)
+	model := newTestOpenAI(t, server, OpenAIConfig{APIKey: "sk-test"})
+
+	_, err := model.Generate(context.Background(), testMessages, GenerateOptions{})
+	if err == nil || !strings.Contains(err.Error(), "no choices") {
+		t.Fatalf("expected no choices error, got %v", err)
+	}
+}
+
+func TestOpenAIGenerateStream(t *testing.T) {
+	chunks := []map[string]interface{}{deltaChunk("Hel"), deltaChunk("lo"), deltaChunk("")}
+	server := newFakeChatServer(t, nil, chunks)
+	model := newTestOpenAI(t, server, OpenAIConfig{APIKey: "sk-test"})
+
+	var deltas []string
+	err := model.GenerateStream(context.Background(), testMessages, GenerateOptions{}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(deltas, "|"); got != "Hel|lo" {
+		t.Errorf("deltas = %q, want %q", got, "Hel|lo")
+	}
+	if stream, _ := server.requests[0]["stream"].(bool); !stream {
+		t.Error("expected stream=true in request")
+	}
+}
+
+func TestOpenAIGenerateStreamCallbackError(t *testing.T) {
+	server := newFakeChatServer(t, nil, []map[string]interface{}{deltaChunk("a"), deltaChunk("b")})
+	model := newTestOpenAI(t, server, OpenAIConfig{APIKey: "sk-test"})
+
+	calls := 0
+	err := model.GenerateStream(context.Background(), testMessages, GenerateOptions{}, func(string) error {
+		calls++
+		return fmt.Errorf("client gone")
+	})
+	if err == nil || !strings.Contains(err.Error(), "client gone") {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("callback called %d times, want 1", calls)
+	}
+}
+
+func TestOpenAIOrganizationAndHeaders(t *testing.T) {
+	server := newFakeChatServer(t, chatCompletion("ok"), nil)
+	model := newTestOpenAI(t, server, OpenAIConfig{
+		APIKey:       "sk-test",
+		Organization: "org-123",
+		Headers:      map[string]string{"X-Gateway-Key": "secret", "X-Tenant": "docs"},
+	})
+
+	if _, err := model.Generate(context.Background(), testMessages, GenerateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	headers := server.headers[0]
+	for name, want := range map[string]string{
+		"OpenAI-Organization": "org-123",
+		"X-Gateway-Key":       "secret",
+		"X-Tenant":            "docs",
+	} {
+		if got := headers.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestOpenAIWithoutAPIKey(t *testing.T) {
+	if _, err := NewOpenAI(&OpenAIConfig{Model: "gpt-test"}); err == nil {
+		t.Error("expected error without API key and base URL")
+	}
+
+	server := newFakeChatServer(t, chatCompletion("ok"), nil)
+	model := newTestOpenAI(t, server, OpenAIConfig{})
+	if _, err := model.Generate(context.Background(), testMessages, GenerateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.headers[0].Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want empty", got)
+	}
+}
+
+func TestNewOpenAIConfigFromEnv(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_BASE_URL", "")
+	if config, err := NewOpenAIConfigFromEnv(); config != nil || err != nil {
+		t.Errorf("expected nil config without key and base URL, got %+v, %v", config, err)
+	}
+
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1")
+	t.Setenv("OPENAI_HEADERS", "X-A=1, bad ,X-B = 2")
+	config, err := NewOpenAIConfigFromEnv()
+	if err != nil || config == nil || config.BaseURL != "http://localhost:11434/v1" {
+		t.Fatalf("expected config for keyless base URL, got %+v, %v", config, err)
+	}
+	if len(config.Headers) != 2 || config.Headers["X-A"] != "1" || config.Headers["X-B"] != "2" {
+		t.Errorf("Headers = %v, want X-A=1 and X-B=2", config.Headers)
+	}
+
+	t.Setenv("OPENAI_TIMEOUT", "soon")
+	if _, err := NewOpenAIConfigFromEnv(); err == nil {
+		t.Error("expected an error for a malformed OPENAI_TIMEOUT")
+	}
+}
+
+func TestOpenAINewChatRequest(t *testing.T) {
+	model, err := NewOpenAI(&OpenAIConfig{APIKey: "sk-test", Model: "gpt-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero, topP, seed := 0.0, 0.5, 7
+
+	req := model.newChatRequest(testMessages, GenerateOptions{}, false)
+	if req.Model != "gpt-test" || req.Temperature != DefaultTemperature || req.MaxCompletionTokens != DefaultMaxTokens {
+		t.Errorf("default request = model %q, temperature %v, max tokens %d", req.Model, req.Temperature, req.MaxCompletionTokens)
+	}
+	if req.ResponseFormat != nil || req.TopP != 0 || req.Seed != nil {
+		t.Errorf("default request should not set response format, top_p or seed: %+v", req)
+	}
+
+	req = model.newChatRequest(testMessages, GenerateOptions{
+		Temperature: &zero,
+		TopP:        &topP,
+		MaxTokens:   16,
+		Stop:        []string{"END"},
+		Seed:        &seed,
+		Model:       "gpt-other",
+		JSONMode:    true,
+	}, true)
+	// temperature 为 0 时 go-openai 会省略该字段，用最小正数代替
+	if req.Temperature != math.SmallestNonzeroFloat32 {
+		t.Errorf("Temperature = %v, want math.SmallestNonzeroFloat32", req.Temperature)
+	}
+	if req.Model != "gpt-other" || req.TopP != 0.5 || req.MaxCompletionTokens != 16 || !req.Stream {
+		t.Errorf("request = %+v", req)
+	}
+	if req.Seed == nil || *req.Seed != 7 || len(req.Stop) != 1 || req.Stop[0] != "END" {
+		t.Errorf("Seed = %v, Stop = %v", req.Seed, req.Stop)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" {
+		t.Errorf("ResponseFormat = %+v, want json_object", req.ResponseFormat)
+	}
+}
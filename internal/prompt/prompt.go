@@ -3,55 +3,206 @@ package prompt
 import (
 	"fmt"
 	"strings"
+	"text/template"
+
+	"goRag/internal/llm"
+)
+
+// 模板中的两个命名块：system 生成系统提示词（可选），user 生成用户提示词（必需）
+const (
+	systemBlock = "system"
+	userBlock   = "user"
 )
 
 // Template 提示模板
+// SystemPrompt 和 UserPrompt 使用 Go text/template 语法，数据为 Data，例如：
+//
+//	{{if .Documents}}Context: {{.Context}}{{else}}No context.{{end}}
+//	{{range .Documents}}[{{.Index}}] ({{index .Metadata "source"}}) {{.Content}}{{end}}
 type Template struct {
-	SystemPrompt string
-	UserPrompt   string
+	Name         string    // 模板名称，用于按名称选择
+	SystemPrompt string    // 系统提示词，作为 system 角色消息发送，渲染结果为空时不发送
+	UserPrompt   string    // 用户提示词
+	Examples     []Example // 可选的少样本示例，按顺序放在历史消息之前
+}
+
+// Example 一组少样本示例（一问一答）
+type Example struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// Document 模板中可访问的一篇检索文档
+type Document struct {
+	Index    int                    // 编号（从 1 开始），与回答中的 [n] 对应
+	ID       string                 // 文档 ID
+	Content  string                 // 文档内容
+	Score    float64                // 检索/排序分数
+	Metadata map[string]interface{} // 文档元数据
+}
+
+// Data 渲染模板的数据
+type Data struct {
+	Query     string     // 用户问题
+	Documents []Document // 检索到的文档，为空表示没有找到相关文档
+	Context   string     // 按 "[n] 内容" 拼接好的上下文，方便简单模板直接使用
+}
+
+// funcs 模板中可用的辅助函数
+var funcs = template.FuncMap{
+	// truncate 按字符截断：{{truncate 200 .Content}}
+	"truncate": func(n int, s string) string {
+		if runes := []rune(s); len(runes) > n {
+			return string(runes[:n]) + "…"
+		}
+		return s
+	},
+	// join 拼接字符串列表：{{join .Tags ", "}}
+	"join": strings.Join,
 }
 
 // Builder 提示构建器
 type Builder struct {
-	template Template
+	name     string
+	tmpl     *template.Template
+	examples []Example
 }
 
-// NewBuilder 创建新的提示构建器
-func NewBuilder(template Template) *Builder {
-	return &Builder{
-		template: template,
+// NewBuilder 解析模板并创建提示构建器
+func NewBuilder(t Template) (*Builder, error) {
+	tmpl := template.New(t.Name).Funcs(funcs)
+	if _, err := tmpl.New(systemBlock).Parse(t.SystemPrompt); err != nil {
+		return nil, fmt.Errorf("failed to parse system prompt of template %q: %w", t.Name, err)
+	}
+	if _, err := tmpl.New(userBlock).Parse(t.UserPrompt); err != nil {
+		return nil, fmt.Errorf("failed to parse user prompt of template %q: %w", t.Name, err)
 	}
+	return newBuilder(t.Name, tmpl, t.Examples)
 }
 
-// Build 构建提示
-func (b *Builder) Build(context string, query string) string {
-	userPrompt := strings.ReplaceAll(b.template.UserPrompt, "{{context}}", context)
-	userPrompt = strings.ReplaceAll(userPrompt, "{{query}}", query)
+// newBuilder 检查必需的命名块并用示例数据试渲染，提前发现字段名拼写等运行期错误
+func newBuilder(name string, tmpl *template.Template, examples []Example) (*Builder, error) {
+	if tmpl.Lookup(userBlock) == nil {
+		return nil, fmt.Errorf("template %q does not define %q", name, userBlock)
+	}
 
-	return fmt.Sprintf("System: %s\n\nUser: %s", b.template.SystemPrompt, userPrompt)
+	b := &Builder{
+		name:     name,
+		tmpl:     tmpl,
+		examples: examples,
+	}
+	for _, data := range sampleData() {
+		if _, err := b.Build(data, nil); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Name 模板名称
+func (b *Builder) Name() string {
+	return b.name
 }
 
+// Build 构建发送给 LLM 的消息列表，顺序为：
+//
+//	system    系统提示词
+//	user      少样本示例问题
+//	assistant 少样本示例回答
+//	...       历史消息（多轮对话）
+//	user      渲染后的用户提示词
+func (b *Builder) Build(data Data, history []llm.Message) ([]llm.Message, error) {
+	messages := make([]llm.Message, 0, 2+2*len(b.examples)+len(history))
+
+	if b.tmpl.Lookup(systemBlock) != nil {
+		systemPrompt, err := b.render(systemBlock, data)
+		if err != nil {
+			return nil, err
+		}
+		if systemPrompt != "" {
+			messages = append(messages, llm.Message{Role: "system", Content: systemPrompt})
+		}
+	}
+	for _, example := range b.examples {
+		messages = append(messages,
+			llm.Message{Role: "user", Content: example.User},
+			llm.Message{Role: "assistant", Content: example.Assistant},
+		)
+	}
+	messages = append(messages, history...)
+
+	userPrompt, err := b.render(userBlock, data)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, llm.Message{Role: "user", Content: userPrompt})
+
+	return messages, nil
+}
+
+// render 渲染命名块并去掉首尾空白
+func (b *Builder) render(block string, data Data) (string, error) {
+	var sb strings.Builder
+	if err := b.tmpl.ExecuteTemplate(&sb, block, data); err != nil {
+		return "", fmt.Errorf("failed to render %s prompt of template %q: %w", block, b.name, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// sampleData 校验模板用的示例数据：有文档和没有文档两种情况
+func sampleData() []Data {
+	return []Data{
+		{
+			Query: "示例问题",
+			Documents: []Document{
+				{Index: 1, ID: "doc1", Content: "示例内容", Score: 1, Metadata: map[string]interface{}{"source": "example"}},
+			},
+			Context: "[1] 示例内容",
+		},
+		{
+			Query: "示例问题",
+		},
+	}
+}
+
+// DefaultTemplateName 默认模板名称
+const DefaultTemplateName = "default"
+
 // DefaultTemplate 默认模板
 func DefaultTemplate() Template {
 	return Template{
-		SystemPrompt: "You are a helpful assistant that answers questions based on the provided context.",
-		UserPrompt:   "Context: {{context}}\n\nQuestion: {{query}}\n\nAnswer:",
+		Name: DefaultTemplateName,
+		SystemPrompt: "You are a helpful assistant that answers questions based on the provided context. " +
+			"Each context passage is numbered like [1]. Cite the passages you use by appending their numbers, e.g. [1] or [2][3].",
+		UserPrompt: "{{if .Documents}}Context: {{.Context}}\n\n" +
+			"{{else}}No relevant context was found. If you cannot answer reliably, say that you don't know.\n\n" +
+			"{{end}}Question: {{.Query}}\n\nAnswer:",
 	}
 }
 
 // Service 提示服务
 type Service struct {
-	builder *Builder
+	registry *Registry
 }
 
 // NewService 创建新的提示服务
-func NewService(template Template) *Service {
+func NewService(registry *Registry) *Service {
 	return &Service{
-		builder: NewBuilder(template),
+		registry: registry,
 	}
 }
 
-// BuildPrompt 构建提示
-func (s *Service) BuildPrompt(context string, query string) string {
-	return s.builder.Build(context, query)
+// HasTemplate 是否存在指定名称的模板，空名称表示默认模板
+func (s *Service) HasTemplate(name string) bool {
+	_, err := s.registry.Get(name)
+	return err == nil
+}
+
+// BuildMessages 使用指定名称的模板（空名称表示默认模板）构建消息列表，history 可以为空
+func (s *Service) BuildMessages(name string, data Data, history []llm.Message) ([]llm.Message, error) {
+	builder, err := s.registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return builder.Build(data, history)
 }
@@ -0,0 +1,168 @@
+package prompt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// ErrTemplateNotFound 模板不存在
+var ErrTemplateNotFound = errors.New("prompt template not found")
+
+const (
+	templateExt = ".tmpl"          // 模板文件扩展名
+	examplesExt = ".examples.json" // 少样本示例文件扩展名
+)
+
+// Registry 按名称管理的提示模板集合
+// 空名称对应 DefaultTemplateName。
+type Registry struct {
+	builders map[string]*Builder
+}
+
+// NewRegistry 创建模板集合，始终包含 DefaultTemplate，同名模板会覆盖默认模板
+func NewRegistry(templates ...Template) (*Registry, error) {
+	r := &Registry{
+		builders: make(map[string]*Builder),
+	}
+	for _, t := range append([]Template{DefaultTemplate()}, templates...) {
+		if t.Name == "" {
+			return nil, fmt.Errorf("template name is required")
+		}
+		builder, err := NewBuilder(t)
+		if err != nil {
+			return nil, err
+		}
+		r.builders[t.Name] = builder
+	}
+	return r, nil
+}
+
+// DefaultRegistry 只包含 DefaultTemplate 的模板集合
+func DefaultRegistry() *Registry {
+	r, err := NewRegistry()
+	if err != nil {
+		// 默认模板是常量，解析失败属于代码错误
+		panic(err)
+	}
+	return r
+}
+
+// LoadRegistry 从目录加载模板，每个 *.tmpl 文件是一个模板，文件名（去掉扩展名）即模板名称。
+// 文件中用命名块定义提示词，user 块必需，system 块可选：
+//
+//	{{define "system"}}你是一个严谨的助手……{{end}}
+//	{{define "user"}}{{range .Documents}}[{{.Index}}] {{.Content}}
+//	{{end}}问题：{{.Query}}{{end}}
+//
+// 少样本示例放在同名的 <模板名>.examples.json 中（可选），按顺序放在历史消息之前：
+//
+//	[{"user": "示例问题", "assistant": "示例回答 [1]"}]
+//
+// 所有模板在加载时解析并试渲染，任何一个出错都会返回错误；没有对应模板的示例文件也视为错误。
+func LoadRegistry(dir string) (*Registry, error) {
+	r, err := NewRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	if len(paths) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("failed to open template directory: %w", err)
+		}
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), templateExt)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+		}
+
+		tmpl, err := template.New(name).Funcs(funcs).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+		}
+		examples, err := loadExamples(filepath.Join(dir, name+examplesExt))
+		if err != nil {
+			return nil, err
+		}
+		builder, err := newBuilder(name, tmpl, examples)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %s: %w", path, err)
+		}
+		r.builders[name] = builder
+	}
+
+	// 示例文件名拼写错误时不会被任何模板使用，提前报错
+	examplePaths, err := filepath.Glob(filepath.Join(dir, "*"+examplesExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list examples: %w", err)
+	}
+	for _, path := range examplePaths {
+		name := strings.TrimSuffix(filepath.Base(path), examplesExt)
+		if !slices.Contains(paths, filepath.Join(dir, name+templateExt)) {
+			return nil, fmt.Errorf("examples file %s has no matching template %s%s", path, name, templateExt)
+		}
+	}
+
+	return r, nil
+}
+
+// loadExamples 读取少样本示例文件，文件不存在时返回 nil
+func loadExamples(path string) ([]Example, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read examples %s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	var examples []Example
+	if err := decoder.Decode(&examples); err != nil {
+		return nil, fmt.Errorf("failed to parse examples %s: %w", path, err)
+	}
+	for i, example := range examples {
+		if strings.TrimSpace(example.User) == "" || strings.TrimSpace(example.Assistant) == "" {
+			return nil, fmt.Errorf("example %d in %s must have both user and assistant", i, path)
+		}
+	}
+	return examples, nil
+}
+
+// Get 按名称获取模板，空名称表示默认模板
+func (r *Registry) Get(name string) (*Builder, error) {
+	if name == "" {
+		name = DefaultTemplateName
+	}
+	builder, ok := r.builders[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrTemplateNotFound, name)
+	}
+	return builder, nil
+}
+
+// Names 全部模板名称（按字母排序）
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.builders))
+	for name := range r.builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,83 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles 在临时目录中写入文件，返回目录路径
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const testTemplate = `{{define "system"}}系统{{end}}{{define "user"}}问题：{{.Query}}{{end}}`
+
+func TestLoadRegistryExamples(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"zh.tmpl":          testTemplate,
+		"zh.examples.json": `[{"user": "问题一", "assistant": "回答一 [1]"}, {"user": "问题二", "assistant": "回答二"}]`,
+		"plain.tmpl":       testTemplate,
+	})
+	r, err := LoadRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder, err := r.Get("zh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := builder.Build(Data{Query: "真正的问题"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, msg := range messages {
+		got = append(got, msg.Role+":"+msg.Content)
+	}
+	want := []string{"system:系统", "user:问题一", "assistant:回答一 [1]", "user:问题二", "assistant:回答二", "user:问题：真正的问题"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+
+	builder, err = r.Get("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages, _ := builder.Build(Data{Query: "q"}, nil); len(messages) != 2 {
+		t.Errorf("template without examples file got %d messages, want 2", len(messages))
+	}
+}
+
+func TestLoadRegistryInvalidExamples(t *testing.T) {
+	tests := []struct {
+		name     string
+		examples string
+		file     string
+		errText  string
+	}{
+		{"malformed json", `[{"user": "q"`, "zh.examples.json", "failed to parse examples"},
+		{"unknown field", `[{"question": "q", "answer": "a"}]`, "zh.examples.json", "failed to parse examples"},
+		{"missing answer", `[{"user": "q"}]`, "zh.examples.json", "must have both user and assistant"},
+		{"no matching template", `[]`, "en.examples.json", "has no matching template"},
+		{"default template is not a file", `[]`, DefaultTemplateName + examplesExt, "has no matching template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"zh.tmpl": testTemplate, tt.file: tt.examples})
+			_, err := LoadRegistry(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("LoadRegistry error = %v, want it to contain %q", err, tt.errText)
+			}
+		})
+	}
+}
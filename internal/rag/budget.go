@@ -0,0 +1,126 @@
+package rag
+
+import (
+	"strings"
+
+	"goRag/internal/llm"
+	"goRag/internal/retriever"
+	"goRag/internal/tokenizer"
+)
+
+// 打包上下文时的固定开销估算
+const (
+	messageOverheadTokens = 4  // 每条消息的角色、分隔符等开销
+	passageOverheadTokens = 4  // 每段上下文的 "[n] " 编号和段落间空行
+	minTruncatedTokens    = 32 // 剩余预算少于该值时不再截断最后一段，直接丢弃
+)
+
+// ContextBudget 上下文 token 预算配置
+// 可用于上下文的 token 数 = 模型上下文窗口 - 回答预留（GenerateOptions.MaxTokens）- 提示词其余部分（系统提示词、历史消息、问题）。
+type ContextBudget struct {
+	Counter       tokenizer.Counter // token 计数器，默认 HeuristicCounter
+	DefaultWindow int               // 未匹配到模型时的上下文窗口
+	ModelWindows  map[string]int    // 按模型名前缀匹配的上下文窗口，最长前缀优先，如 "qwen2.5" 匹配 "qwen2.5:3b-instruct"
+}
+
+// DefaultContextBudget 默认上下文预算
+// 注意 Ollama 实际使用的窗口由 num_ctx 决定（默认远小于模型支持的长度），需要时用 DefaultWindow 或 ModelWindows 覆盖。
+func DefaultContextBudget() ContextBudget {
+	return ContextBudget{
+		Counter:       tokenizer.NewHeuristicCounter(),
+		DefaultWindow: 8192,
+		ModelWindows: map[string]int{
+			"gpt-4o":        128000,
+			"gpt-4-turbo":   128000,
+			"gpt-4":         8192,
+			"gpt-3.5-turbo": 16385,
+			"qwen2.5":       32768,
+			"llama3":        8192,
+			"llama2":        4096,
+		},
+	}
+}
+
+// Window 返回模型的上下文窗口
+func (b ContextBudget) Window(model string) int {
+	window, matched := b.DefaultWindow, 0
+	for prefix, w := range b.ModelWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > matched {
+			window, matched = w, len(prefix)
+		}
+	}
+	return window
+}
+
+// countMessages 统计消息列表的 token 数
+func (b ContextBudget) countMessages(messages []llm.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += b.Counter.Count(msg.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// PackingReport 上下文打包结果
+type PackingReport struct {
+	Budget    int      // 可用于上下文的 token 数
+	Used      int      // 实际放入的上下文 token 数
+	Truncated []string // 被截断的文档 ID（最多一篇，即最后放入的一篇）
+	Dropped   []string // 放不下而被丢弃的文档 ID（按排序顺序）
+}
+
+// pack 按排序顺序把检索结果放入预算：
+//  1. 依次放入完整的段落，直到下一段放不下
+//  2. 在句子边界截断放不下的那一段，剩余预算太少或一句都放不下时丢弃它
+//  3. 其余段落全部丢弃
+func (b ContextBudget) pack(results []retriever.RetrievalResult, budget int) ([]retriever.RetrievalResult, *PackingReport) {
+	report := &PackingReport{
+		Budget:    max(budget, 0),
+		Truncated: []string{},
+		Dropped:   []string{},
+	}
+	packed := make([]retriever.RetrievalResult, 0, len(results))
+
+	remaining := report.Budget
+	for i, result := range results {
+		cost := b.Counter.Count(result.Document.Content) + passageOverheadTokens
+		if cost <= remaining {
+			packed = append(packed, result)
+			remaining -= cost
+			continue
+		}
+
+		dropFrom := i
+		if limit := remaining - passageOverheadTokens; limit >= minTruncatedTokens {
+			if content := b.truncate(result.Document.Content, limit); content != "" {
+				result.Document.Content = content
+				packed = append(packed, result)
+				remaining -= b.Counter.Count(content) + passageOverheadTokens
+				report.Truncated = append(report.Truncated, result.Document.ID)
+				dropFrom = i + 1
+			}
+		}
+		for _, dropped := range results[dropFrom:] {
+			report.Dropped = append(report.Dropped, dropped.Document.ID)
+		}
+		break
+	}
+
+	report.Used = report.Budget - remaining
+	return packed, report
+}
+
+// truncate 在句子边界截断文本，使其不超过 limit 个 token；一句都放不下时返回空字符串
+func (b ContextBudget) truncate(text string, limit int) string {
+	var sb strings.Builder
+	used := 0
+	for _, sentence := range tokenizer.SplitSentences(text) {
+		cost := b.Counter.Count(sentence)
+		if used+cost > limit {
+			break
+		}
+		sb.WriteString(sentence)
+		used += cost
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,198 @@
+package rag
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"goRag/internal/llm"
+	"goRag/internal/retriever"
+	"goRag/internal/tokenizer"
+)
+
+// runeBudget 每个字符记 1 个 token 的预算，便于计算期望值
+func runeBudget() ContextBudget {
+	return ContextBudget{
+		Counter:       tokenizer.CounterFunc(utf8.RuneCountInString),
+		DefaultWindow: 1000,
+		ModelWindows: map[string]int{
+			"small":       2000,
+			"small-large": 4000,
+		},
+	}
+}
+
+func packResults(contents ...string) []retriever.RetrievalResult {
+	results := make([]retriever.RetrievalResult, len(contents))
+	for i, content := range contents {
+		results[i] = retriever.RetrievalResult{Document: retriever.Document{
+			ID:      string(rune('a' + i)),
+			Content: content,
+		}}
+	}
+	return results
+}
+
+func TestContextBudgetPack(t *testing.T) {
+	ten := strings.Repeat("x", 10)                   // 10 + 4 = 14 个 token
+	sentence := strings.Repeat("y", 20) + ". "       // 22 个 token
+	threeSentences := sentence + sentence + sentence // 66 个 token
+
+	tests := []struct {
+		name          string
+		contents      []string
+		budget        int
+		wantIDs       []string
+		wantUsed      int
+		wantTruncated []string
+		wantDropped   []string
+		wantLast      string // 最后一段放入的内容，空表示不检查
+	}{
+		{
+			name:          "all fit",
+			contents:      []string{ten, ten, ten},
+			budget:        100,
+			wantIDs:       []string{"a", "b", "c"},
+			wantUsed:      42,
+			wantTruncated: []string{},
+			wantDropped:   []string{},
+		},
+		{
+			name:          "exact fit",
+			contents:      []string{ten, ten},
+			budget:        28,
+			wantIDs:       []string{"a", "b"},
+			wantUsed:      28,
+			wantTruncated: []string{},
+			wantDropped:   []string{},
+		},
+		{
+			name:          "truncate at sentence boundary",
+			contents:      []string{ten, threeSentences, ten},
+			budget:        14 + 4 + 50,
+			wantIDs:       []string{"a", "b"},
+			wantUsed:      14 + 43 + 4,
+			wantTruncated: []string{"b"},
+			wantDropped:   []string{"c"},
+			wantLast:      strings.TrimSpace(sentence + sentence),
+		},
+		{
+			name:          "too little left to truncate",
+			contents:      []string{ten, threeSentences, ten},
+			budget:        14 + 4 + 20,
+			wantIDs:       []string{"a"},
+			wantUsed:      14,
+			wantTruncated: []string{},
+			wantDropped:   []string{"b", "c"},
+		},
+		{
+			name:          "first sentence does not fit",
+			contents:      []string{ten, strings.Repeat("z", 100), ten},
+			budget:        14 + 4 + 50,
+			wantIDs:       []string{"a"},
+			wantUsed:      14,
+			wantTruncated: []string{},
+			wantDropped:   []string{"b", "c"},
+		},
+		{
+			name:          "negative budget",
+			contents:      []string{ten},
+			budget:        -10,
+			wantIDs:       []string{},
+			wantUsed:      0,
+			wantTruncated: []string{},
+			wantDropped:   []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := packResults(tt.contents...)
+			packed, report := runeBudget().pack(results, tt.budget)
+
+			ids := make([]string, len(packed))
+			for i, r := range packed {
+				ids[i] = r.Document.ID
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("packed = %v, want %v", ids, tt.wantIDs)
+			}
+			if report.Budget != max(tt.budget, 0) || report.Used != tt.wantUsed {
+				t.Errorf("budget = %d, used = %d, want %d, %d", report.Budget, report.Used, max(tt.budget, 0), tt.wantUsed)
+			}
+			if !slices.Equal(report.Truncated, tt.wantTruncated) || !slices.Equal(report.Dropped, tt.wantDropped) {
+				t.Errorf("truncated = %v, dropped = %v, want %v, %v", report.Truncated, report.Dropped, tt.wantTruncated, tt.wantDropped)
+			}
+			if tt.wantLast != "" && packed[len(packed)-1].Document.Content != tt.wantLast {
+				t.Errorf("last content = %q, want %q", packed[len(packed)-1].Document.Content, tt.wantLast)
+			}
+			// 截断只修改副本
+			for i, r := range results {
+				if r.Document.Content != tt.contents[i] {
+					t.Errorf("input result %d was modified", i)
+				}
+			}
+		})
+	}
+}
+
+func TestContextBudgetWindow(t *testing.T) {
+	budget := DefaultContextBudget()
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"qwen2.5:3b-instruct", 32768},
+		{"gpt-4o-mini", 128000},
+		{"gpt-4-turbo-preview", 128000},
+		{"gpt-4-0613", 8192},
+		{"llama2:7b", 4096},
+		{"mistral", 8192},
+		{"", 8192},
+	}
+	for _, tt := range tests {
+		if got := budget.Window(tt.model); got != tt.want {
+			t.Errorf("Window(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestPackContextUsesModelWindow(t *testing.T) {
+	service := NewRAGService(nil, nil, llm.NewService(llm.NewMockLLM()), WithContextBudget(runeBudget()))
+	results := packResults("alpha")
+
+	budgets := make(map[string]int)
+	for _, model := range []string{"", "small", "small-large:7b"} {
+		opts := QueryOptions{Generation: llm.GenerateOptions{Model: model, MaxTokens: 100}}
+		_, report, err := service.packContext("q", results, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		budgets[model] = report.Budget
+	}
+
+	// 提示词其余部分的开销相同，预算之差等于窗口之差
+	if got := budgets["small"] - budgets[""]; got != 1000 {
+		t.Errorf("small budget - default budget = %d, want 1000", got)
+	}
+	if got := budgets["small-large:7b"] - budgets["small"]; got != 2000 {
+		t.Errorf("longest prefix budget - small budget = %d, want 2000", got)
+	}
+	if budgets[""] <= 0 || budgets[""] >= 1000-100 {
+		t.Errorf("default budget = %d, want within (0, 900)", budgets[""])
+	}
+}
+
+func TestWithContextBudgetDefaultsCounter(t *testing.T) {
+	budget := runeBudget()
+	budget.Counter = nil
+	service := NewRAGService(nil, nil, llm.NewService(llm.NewMockLLM()), WithContextBudget(budget))
+	if service.contextBudget.Counter == nil {
+		t.Fatal("expected a default counter")
+	}
+
+	messages := []llm.Message{{Role: "system", Content: "你好"}, {Role: "user", Content: "hello"}}
+	if got := runeBudget().countMessages(messages); got != 2+5+2*messageOverheadTokens {
+		t.Errorf("countMessages = %d, want %d", got, 2+5+2*messageOverheadTokens)
+	}
+}
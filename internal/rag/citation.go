@@ -0,0 +1,116 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"goRag/internal/prompt"
+	"goRag/internal/retriever"
+)
+
+// snippetLength 来源摘要的最大字符数
+const snippetLength = 200
+
+// Source 回答所依据的一篇检索文档
+type Source struct {
+	Index    int                    // 在提示词中的编号（从 1 开始），与回答中的 [n] 对应
+	ID       string                 // 文档 ID
+	Score    float64                // 检索/排序分数
+	Metadata map[string]interface{} // 文档元数据
+	Snippet  string                 // 内容摘要
+}
+
+// Citation 回答中的一处引用
+type Citation struct {
+	Marker     int    // 引用编号，如 [2] 中的 2
+	DocumentID string // 对应的文档 ID
+}
+
+// QueryResult 查询结果
+type QueryResult struct {
+	Answer    string         // LLM 生成的回答
+	Sources   []Source       // 提供给 LLM 的全部文档
+	Citations []Citation     // 回答中实际引用到的文档（按首次出现顺序去重）
+	Packing   *PackingReport // 上下文打包情况（预算、截断和丢弃的文档），没有检索到文档时为 nil
+}
+
+// buildSources 为检索结果编号并生成摘要
+func buildSources(results []retriever.RetrievalResult) []Source {
+	sources := make([]Source, len(results))
+	for i, result := range results {
+		sources[i] = Source{
+			Index:    i + 1,
+			ID:       result.Document.ID,
+			Score:    result.Score,
+			Metadata: result.Document.Metadata,
+			Snippet:  snippet(result.Document.Content, snippetLength),
+		}
+	}
+	return sources
+}
+
+// buildNumberedContext 把检索结果拼接成带编号的上下文：
+//
+//	[1] 文档内容1
+//
+//	[2] 文档内容2
+func buildNumberedContext(results []retriever.RetrievalResult) string {
+	parts := make([]string, len(results))
+	for i, result := range results {
+		parts[i] = fmt.Sprintf("[%d] %s", i+1, result.Document.Content)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// buildPromptDocuments 把检索结果转换为模板数据，编号与 buildNumberedContext 一致
+func buildPromptDocuments(results []retriever.RetrievalResult) []prompt.Document {
+	documents := make([]prompt.Document, len(results))
+	for i, result := range results {
+		documents[i] = prompt.Document{
+			Index:    i + 1,
+			ID:       result.Document.ID,
+			Content:  result.Document.Content,
+			Score:    result.Score,
+			Metadata: result.Document.Metadata,
+		}
+	}
+	return documents
+}
+
+// citationPattern 匹配 [1]、[1, 3]、[1][2]、【2】、［3］ 等引用标记
+var citationPattern = regexp.MustCompile(`[\[【［]\s*(\d+(?:\s*[,，、]\s*\d+)*)\s*[\]】］]`)
+
+// parseCitations 解析回答中的引用标记并映射回文档 ID，超出范围的编号会被忽略
+func parseCitations(answer string, sources []Source) []Citation {
+	citations := make([]Citation, 0)
+	seen := make(map[int]bool)
+
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		numbers := strings.FieldsFunc(match[1], func(r rune) bool {
+			return r == ',' || r == '，' || r == '、' || r == ' '
+		})
+		for _, n := range numbers {
+			marker, err := strconv.Atoi(n)
+			if err != nil || marker < 1 || marker > len(sources) || seen[marker] {
+				continue
+			}
+			seen[marker] = true
+			citations = append(citations, Citation{
+				Marker:     marker,
+				DocumentID: sources[marker-1].ID,
+			})
+		}
+	}
+	return citations
+}
+
+// snippet 截取前 n 个字符作为摘要
+func snippet(content string, n int) string {
+	runes := []rune(strings.TrimSpace(content))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,53 @@
+package rag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCitations(t *testing.T) {
+	sources := []Source{{Index: 1, ID: "a"}, {Index: 2, ID: "b"}, {Index: 3, ID: "c"}}
+
+	tests := []struct {
+		name   string
+		answer string
+		want   []Citation
+	}{
+		{"single", "Go 由 Google 开发 [1]。", []Citation{{1, "a"}}},
+		{"comma list", "见 [1, 3]", []Citation{{1, "a"}, {3, "c"}}},
+		{"adjacent markers", "见[2][1]", []Citation{{2, "b"}, {1, "a"}}},
+		{"full-width brackets with enumeration comma", "答案【1、2】", []Citation{{1, "a"}, {2, "b"}}},
+		{"full-width square brackets", "参考［3］", []Citation{{3, "c"}}},
+		{"full-width comma and spaces", "参考[ 3 ， 1 ]", []Citation{{3, "c"}, {1, "a"}}},
+		{"out of range", "见 [0] 和 [4]", []Citation{}},
+		{"partly out of range", "见 [2, 7]", []Citation{{2, "b"}}},
+		{"repeated", "[2] 以及 [2]，还有 [1][2]", []Citation{{2, "b"}, {1, "a"}}},
+		{"overflowing number", "[99999999999999999999]", []Citation{}},
+		{"not a citation", "数组 a[i] 和 [x]", []Citation{}},
+		{"no citation", "没有引用", []Citation{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCitations(tt.answer, sources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCitations(%q) = %v, want %v", tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		content string
+		n       int
+		want    string
+	}{
+		{"  short  ", 10, "short"},
+		{"检索增强生成", 4, "检索增强…"},
+		{"exact", 5, "exact"},
+	}
+	for _, tt := range tests {
+		if got := snippet(tt.content, tt.n); got != tt.want {
+			t.Errorf("snippet(%q, %d) = %q, want %q", tt.content, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"goRag/internal/llm"
+	"goRag/internal/session"
+)
+
+// condenseSystemPrompt 把追问改写成独立问题的系统提示词
+const condenseSystemPrompt = `Given a conversation and a follow-up question, rewrite the follow-up question as a standalone question
+that can be understood without the conversation. Resolve pronouns and omitted subjects using the conversation.
+Keep the language of the follow-up question. If it is already standalone, return it unchanged.
+Output only the standalone question.`
+
+// ChatResult 多轮对话中一轮的结果
+type ChatResult struct {
+	*QueryResult
+	ConversationID  string // 会话 ID
+	StandaloneQuery string // 改写后用于检索的独立问题，没有历史时与原问题相同
+}
+
+// CreateConversation 创建新的会话
+func (r *RAGService) CreateConversation(ctx context.Context) (*session.Session, error) {
+	return r.sessions.Create(ctx)
+}
+
+// GetConversation 获取会话及其历史消息，不存在时返回 session.ErrNotFound
+func (r *RAGService) GetConversation(ctx context.Context, id string) (*session.Session, error) {
+	return r.sessions.Get(ctx, id)
+}
+
+// ListConversations 分页列出会话，按最近更新时间倒序，返回当前页和会话总数
+func (r *RAGService) ListConversations(ctx context.Context, offset, limit int) ([]*session.Session, int, error) {
+	return r.sessions.List(ctx, offset, limit)
+}
+
+// DeleteConversation 删除会话，不存在时返回 session.ErrNotFound
+func (r *RAGService) DeleteConversation(ctx context.Context, id string) error {
+	return r.sessions.Delete(ctx, id)
+}
+
+// Chat 在会话中继续提问
+// 流程：
+//  1. 读取会话历史
+//  2. 有历史时用 LLM 把追问（如"他哪一年入党？"）改写成独立问题，用于检索和排序
+//  3. 按 Query 的流程检索、排序，并把最近的历史消息放在本轮提示词之前
+//  4. 生成回答，把本轮的原始问题和回答追加到会话
+func (r *RAGService) Chat(ctx context.Context, conversationID string, query string, opts QueryOptions) (*ChatResult, error) {
+	if r.llmService == nil {
+		return nil, fmt.Errorf("llm service is not initialized")
+	}
+
+	conversation, err := r.sessions.Get(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	history := recentMessages(conversation.Messages, r.historyMessages)
+
+	standalone := r.condenseQuery(ctx, query, history)
+
+	prepared, err := r.prepare(ctx, standalone, history, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueryResult{Answer: noDocumentsAnswer}
+	if !prepared.skipGeneration {
+		answer, err := r.llmService.Generate(ctx, prepared.messages, opts.Generation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate answer: %w", err)
+		}
+		result = prepared.result(answer)
+	}
+
+	err = r.sessions.Append(ctx, conversationID,
+		llm.Message{Role: "user", Content: query},
+		llm.Message{Role: "assistant", Content: result.Answer},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save conversation: %w", err)
+	}
+
+	return &ChatResult{
+		QueryResult:     result,
+		ConversationID:  conversationID,
+		StandaloneQuery: standalone,
+	}, nil
+}
+
+// condenseQuery 结合历史把追问改写成独立问题
+// 没有历史或改写失败时返回原问题，不影响主流程
+func (r *RAGService) condenseQuery(ctx context.Context, query string, history []llm.Message) string {
+	if len(history) == 0 {
+		return query
+	}
+
+	var transcript strings.Builder
+	for _, msg := range history {
+		role := "User"
+		if msg.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", role, msg.Content)
+	}
+
+	messages := []llm.Message{
+		{Role: "system", Content: condenseSystemPrompt},
+		{Role: "user", Content: fmt.Sprintf("Conversation:\n%s\nFollow-up question: %s\n\nStandalone question:", transcript.String(), query)},
+	}
+
+	temperature := 0.0
+	standalone, err := r.llmService.Generate(ctx, messages, llm.GenerateOptions{
+		Temperature: &temperature,
+		MaxTokens:   200,
+	})
+	if err != nil {
+		log.Printf("failed to condense follow-up question, using it as is: %v", err)
+		return query
+	}
+
+	standalone = strings.TrimSpace(standalone)
+	if standalone == "" {
+		return query
+	}
+	log.Printf("condensed follow-up question %q into %q", query, standalone)
+	return standalone
+}
+
+// recentMessages 返回最近的 n 条消息
+func recentMessages(messages []llm.Message, n int) []llm.Message {
+	if n <= 0 {
+		return nil
+	}
+	if len(messages) > n {
+		return messages[len(messages)-n:]
+	}
+	return messages
+}
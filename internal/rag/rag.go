@@ -4,13 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
 
 	"goRag/internal/embedding"
 	"goRag/internal/llm"
 	"goRag/internal/prompt"
 	"goRag/internal/ranker"
 	"goRag/internal/retriever"
+	"goRag/internal/session"
+	"goRag/internal/tokenizer"
 )
 
 // RAGService RAG 服务
@@ -20,6 +21,78 @@ type RAGService struct {
 	rankerService    *ranker.Service
 	promptService    *prompt.Service
 	llmService       *llm.Service
+
+	candidateMultiplier int           // 检索阶段多取 topK * candidateMultiplier 个候选交给排序器
+	sessions            session.Store // 多轮对话的会话存储
+	historyMessages     int           // 多轮对话时带入提示词和问题改写的历史消息数
+
+	answerWithoutContext bool          // 没有检索到文档时是否仍然调用 LLM
+	contextBudget        ContextBudget // 上下文 token 预算
+	expandWindow         int           // 默认的分块扩展窗口，见 QueryOptions.ExpandWindow
+}
+
+// Option RAG 服务配置项
+type Option func(*RAGService)
+
+// WithRanker 设置排序器（可以是 ranker.Chain），默认使用 SimpleRanker
+func WithRanker(r ranker.Ranker) Option {
+	return func(s *RAGService) {
+		s.rankerService = ranker.NewService(r)
+	}
+}
+
+// WithCandidateMultiplier 设置排序前的候选倍数，n <= 1 表示不多取
+func WithCandidateMultiplier(n int) Option {
+	return func(s *RAGService) {
+		s.candidateMultiplier = max(n, 1)
+	}
+}
+
+// WithPromptRegistry 设置提示模板集合，查询时按 QueryOptions.Template 选择，默认只有 prompt.DefaultTemplate
+func WithPromptRegistry(registry *prompt.Registry) Option {
+	return func(s *RAGService) {
+		s.promptService = prompt.NewService(registry)
+	}
+}
+
+// WithAnswerWithoutContext 没有检索到文档时仍然调用 LLM（由模板的 {{if .Documents}} 分支决定如何回答），
+// 默认直接返回 "No relevant documents found."
+func WithAnswerWithoutContext(enabled bool) Option {
+	return func(s *RAGService) {
+		s.answerWithoutContext = enabled
+	}
+}
+
+// WithContextBudget 设置上下文 token 预算，Counter 为空时使用启发式计数器
+func WithContextBudget(budget ContextBudget) Option {
+	return func(s *RAGService) {
+		if budget.Counter == nil {
+			budget.Counter = tokenizer.NewHeuristicCounter()
+		}
+		s.contextBudget = budget
+	}
+}
+
+// WithExpandWindow 设置默认的分块扩展窗口（small-to-big 检索），需要检索器配置了切分器
+// 0（默认）不扩展，n > 0 带上命中分块前后各 n 个相邻分块，retriever.ExpandWhole 返回整篇原文档
+func WithExpandWindow(n int) Option {
+	return func(s *RAGService) {
+		s.expandWindow = n
+	}
+}
+
+// WithSessionStore 设置会话存储，默认使用内存存储
+func WithSessionStore(store session.Store) Option {
+	return func(s *RAGService) {
+		s.sessions = store
+	}
+}
+
+// WithHistoryMessages 设置多轮对话时带入的历史消息数（一问一答为 2 条），n <= 0 表示不带历史
+func WithHistoryMessages(n int) Option {
+	return func(s *RAGService) {
+		s.historyMessages = max(n, 0)
+	}
 }
 
 // NewRAGService 创建新的 RAG 服务（使用依赖注入）
@@ -27,16 +100,36 @@ func NewRAGService(
 	embeddingService *embedding.Service,
 	retrieverService *retriever.Service,
 	llmService *llm.Service,
+	opts ...Option,
 ) *RAGService {
-	promptTemplate := prompt.DefaultTemplate()
-
-	return &RAGService{
-		embeddingService: embeddingService,
-		retrieverService: retrieverService,
-		rankerService:    ranker.NewService(ranker.NewSimpleRanker()),
-		promptService:    prompt.NewService(promptTemplate),
-		llmService:       llmService,
+	s := &RAGService{
+		embeddingService:    embeddingService,
+		retrieverService:    retrieverService,
+		rankerService:       ranker.NewService(ranker.AdaptScoreRanker(ranker.NewSimpleRanker())),
+		promptService:       prompt.NewService(prompt.DefaultRegistry()),
+		llmService:          llmService,
+		candidateMultiplier: 3,
+		sessions:            session.NewMemoryStore(session.DefaultMaxMessages),
+		historyMessages:     6,
+		contextBudget:       DefaultContextBudget(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
+}
+
+// QueryOptions 单次查询的可选参数
+type QueryOptions struct {
+	TopK       int                 // 返回最相关的 K 个文档
+	Filter     *retriever.Filter   // 元数据过滤条件，nil 表示不过滤
+	MMRLambda  *float64            // 设置后在排序链之后做 MMR 多样化，取值 [0, 1]，越小越多样
+	Generation llm.GenerateOptions // LLM 生成参数，零值使用默认值
+	Template   string              // 提示模板名称，空表示默认模板
+
+	// ExpandWindow 覆盖 WithExpandWindow 的默认值：0 不扩展，n > 0 带上命中分块前后各 n 个相邻分块，
+	// retriever.ExpandWhole（-1）返回整篇原文档
+	ExpandWindow *int
 }
 
 // Query 查询并生成回答
@@ -44,9 +137,11 @@ func NewRAGService(
 //
 // 流程说明：
 // 1. 检索：根据用户问题，在文档库中找到最相关的文档
-// 2. 构建上下文：把检索到的文档内容组合起来
-// 3. 构建提示词：把问题和上下文组合成 LLM 能理解的格式
-// 4. 生成回答：调用 LLM 基于上下文生成回答
+// 2. 排序：多取一些候选，经过排序器重排后截取 topK
+// 3. 构建上下文：把检索到的文档内容组合起来
+// 4. 构建提示词：把问题和上下文组合成 LLM 能理解的格式
+// 5. 生成回答：调用 LLM 基于上下文生成回答
+// 6. 解析引用：把回答中的 [n] 标记映射回文档
 //
 // 参数：
 //   - ctx: 上下文（用于超时控制等）
@@ -54,70 +149,260 @@ func NewRAGService(
 //   - topK: 返回最相关的 K 个文档（比如 topK=5 表示找 5 个最相关的）
 //
 // 返回：
-//   - string: LLM 生成的回答
+//   - *QueryResult: LLM 生成的回答、参考的文档以及回答中引用的文档
 //   - error: 错误信息
-func (r *RAGService) Query(ctx context.Context, query string, topK int) (string, error) {
+func (r *RAGService) Query(ctx context.Context, query string, topK int) (*QueryResult, error) {
+	return r.QueryWithOptions(ctx, query, QueryOptions{TopK: topK})
+}
+
+// QueryWithOptions 使用完整参数查询并生成回答，流程与 Query 相同
+func (r *RAGService) QueryWithOptions(ctx context.Context, query string, opts QueryOptions) (*QueryResult, error) {
+	// 步骤 1-4：检索、排序、构建上下文和提示词
+	prepared, err := r.prepare(ctx, query, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 如果没有找到相关文档，直接返回
+	if prepared.skipGeneration {
+		return &QueryResult{Answer: noDocumentsAnswer}, nil
+	}
+
+	// ========== 步骤 5: 生成回答 ==========
+	// 调用 LLM，让它基于提示词生成回答
+	// LLM 会看到：
+	// - 用户的问题
+	// - 相关的文档内容
+	// 然后基于这些信息生成回答
+	answer, err := r.llmService.Generate(ctx, prepared.messages, opts.Generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate answer: %w", err)
+	}
+
+	// ========== 步骤 6: 解析引用 ==========
+	// 把回答中的 [n] 标记映射回文档 ID
+	return prepared.result(answer), nil
+}
+
+// noDocumentsAnswer 没有检索到任何文档时的回答
+const noDocumentsAnswer = "No relevant documents found."
+
+// preparedQuery 生成回答之前的中间结果
+type preparedQuery struct {
+	sources        []Source      // 编号后的检索结果，为空表示没有找到相关文档
+	messages       []llm.Message // 发送给 LLM 的消息
+	skipGeneration bool          // 没有找到相关文档且不允许无上下文回答，直接返回 noDocumentsAnswer
+	packing        *PackingReport
+}
+
+// result 根据 LLM 的回答组装查询结果
+func (p *preparedQuery) result(answer string) *QueryResult {
+	return &QueryResult{
+		Answer:    answer,
+		Sources:   p.sources,
+		Citations: parseCitations(answer, p.sources),
+		Packing:   p.packing,
+	}
+}
+
+// prepare 执行生成回答之前的步骤：检索、排序、构建上下文和提示词
+// history 为多轮对话的历史消息，会放在本轮提示词之前
+func (r *RAGService) prepare(ctx context.Context, query string, history []llm.Message, opts QueryOptions) (*preparedQuery, error) {
 	// 检查服务是否初始化
 	if r.retrieverService == nil {
-		return "", fmt.Errorf("retriever service is not initialized")
+		return nil, fmt.Errorf("retriever service is not initialized")
 	}
 	if r.llmService == nil {
-		return "", fmt.Errorf("llm service is not initialized")
+		return nil, fmt.Errorf("llm service is not initialized")
+	}
+	if !r.promptService.HasTemplate(opts.Template) {
+		return nil, fmt.Errorf("%w: %q", prompt.ErrTemplateNotFound, opts.Template)
+	}
+	if opts.TopK < 0 {
+		return nil, fmt.Errorf("topK must be non-negative, got %d", opts.TopK)
 	}
 
-	// ========== 步骤 1: 检索相关文档 ==========
-	// 这里会：
-	// 1. 把用户问题转换成向量（在 Retriever 内部调用 Embedding）
-	// 2. 计算问题向量和所有文档向量的相似度
-	// 3. 返回相似度最高的 topK 个文档
-	results, err := r.retrieverService.Retrieve(ctx, query, topK)
+	// ========== 步骤 1、2: 检索相关文档并排序 ==========
+	results, err := r.retrieve(ctx, query, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to retrieve documents: %w", err)
+		return nil, err
 	}
 
-	// 如果没有找到相关文档，直接返回
-	if len(results) == 0 {
-		return "No relevant documents found.", nil
+	// ========== 步骤 3: 按 token 预算打包上下文 ==========
+	// 优先放入排名靠前的完整段落，放不下的那一段在句子边界截断，其余丢弃
+	var packing *PackingReport
+	if len(results) > 0 {
+		results, packing, err = r.packContext(query, results, history, opts)
+		if err != nil {
+			return nil, err
+		}
 	}
-	log.Println("retrieved documents: ", results)
 
-	// ========== 步骤 2: 构建上下文 ==========
-	// 把检索到的文档内容提取出来，组合成一个长文本
-	// 这个长文本就是 LLM 的"参考资料"
-	contextParts := make([]string, 0, len(results))
-	for _, result := range results {
-		contextParts = append(contextParts, result.Document.Content)
+	if len(results) == 0 && !r.answerWithoutContext {
+		return &preparedQuery{skipGeneration: true}, nil
 	}
-	context := strings.Join(contextParts, "\n\n") // 用两个换行符分隔不同文档
+	sources := buildSources(results)
+	log.Println("retrieved documents: ", sources)
 
-	// ========== 步骤 3: 构建提示词 ==========
-	// 把用户问题和检索到的文档内容组合成提示词
-	// 提示词格式类似：
-	//   Context: [文档内容1]\n\n[文档内容2]
-	//   Question: [用户问题]
-	//   Answer:
-	promptText := r.promptService.BuildPrompt(context, query)
+	// 把检索到的文档内容提取出来，按顺序编号后组合成一个长文本
+	// 这个长文本就是 LLM 的"参考资料"，编号用于让 LLM 以 [n] 的形式标注引用
+	data := prompt.Data{
+		Query:     query,
+		Documents: buildPromptDocuments(results),
+		Context:   buildNumberedContext(results),
+	}
 
-	// ========== 步骤 4: 生成回答 ==========
-	// 调用 LLM，让它基于提示词生成回答
-	// LLM 会看到：
-	// - 用户的问题
-	// - 相关的文档内容
-	// 然后基于这些信息生成回答
-	messages := []llm.Message{
-		{
-			Role:    "user",
-			Content: promptText,
-		},
+	// ========== 步骤 4: 构建提示词 ==========
+	// 用选定的模板把用户问题和检索到的文档内容渲染成消息列表，默认模板为：
+	//   system: 系统提示词
+	//   (少样本示例、历史消息)
+	//   user:   Context: [1] 文档内容1\n\n[2] 文档内容2
+	//           Question: [用户问题]
+	//           Answer:
+	messages, err := r.promptService.BuildMessages(opts.Template, data, history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build prompt: %w", err)
 	}
 	log.Println("prompt messages: ", messages)
 
-	answer, err := r.llmService.Generate(ctx, messages)
+	return &preparedQuery{
+		sources:  sources,
+		messages: messages,
+		packing:  packing,
+	}, nil
+}
+
+// maxExpandRounds 扩展后不足 topK 条时加倍候选数重新检索的最多次数
+const maxExpandRounds = 3
+
+// retrieve 检索、排序并扩展分块，返回最多 topK 条结果
+//
+// 扩展会把同一原文档的多个分块合并为一条，结果可能少于 topK。排序后的分块本来够 topK 条、
+// 且检索器返回了全部候选（可能还有更多文档）时，加倍候选数重新检索，最多 maxExpandRounds 次，仍然不足时记录日志。
+func (r *RAGService) retrieve(ctx context.Context, query string, opts QueryOptions) ([]retriever.RetrievalResult, error) {
+	window := r.expandWindow
+	if opts.ExpandWindow != nil {
+		window = *opts.ExpandWindow
+	}
+
+	candidates := opts.TopK * r.candidateMultiplier
+	for round := 0; ; round++ {
+		// 这里会：
+		// 1. 把用户问题转换成向量（在 Retriever 内部调用 Embedding）
+		// 2. 计算问题向量和所有文档向量的相似度
+		// 3. 返回相似度最高的 candidates 个候选（设置了过滤条件时只在满足条件的文档中选）
+		results, err := r.retrieverService.Retrieve(ctx, query, candidates, opts.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve documents: %w", err)
+		}
+		retrieved := len(results)
+
+		// 排序器可能过滤低分结果或调整顺序，可选的 MMR 去除近似重复的段落
+		results, err = r.rank(ctx, query, results, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rank documents: %w", err)
+		}
+		ranked := len(results)
+
+		// 文档切分过时，把命中的小分块扩展为原文档或相邻分块窗口，同一原文档只保留一条，最后截取 topK
+		results, err = r.retrieverService.Expand(ctx, results, window)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand documents: %w", err)
+		}
+		if len(results) >= opts.TopK {
+			return results[:opts.TopK], nil
+		}
+		if ranked == len(results) || ranked < opts.TopK {
+			// 没有分块被合并，或排序后本来就不足 topK 条，重新检索也无济于事
+			return results, nil
+		}
+		if retrieved < candidates || round == maxExpandRounds {
+			log.Printf("expanded %d chunks into %d distinct documents, fewer than top_k %d", ranked, len(results), opts.TopK)
+			return results, nil
+		}
+		candidates *= 2
+	}
+}
+
+// packContext 计算本次查询可用于上下文的 token 数并打包检索结果
+// 提示词其余部分的开销用不含文档的渲染结果估算。
+func (r *RAGService) packContext(query string, results []retriever.RetrievalResult, history []llm.Message, opts QueryOptions) ([]retriever.RetrievalResult, *PackingReport, error) {
+	skeleton, err := r.promptService.BuildMessages(opts.Template, prompt.Data{Query: query}, history)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate answer: %w", err)
+		return nil, nil, fmt.Errorf("failed to build prompt: %w", err)
+	}
+
+	model := opts.Generation.Model
+	if model == "" {
+		model = r.llmService.GetModelName()
+	}
+	reserved := opts.Generation.MaxTokens
+	if reserved <= 0 {
+		reserved = llm.DefaultMaxTokens
+	}
+
+	budget := r.contextBudget.Window(model) - reserved - r.contextBudget.countMessages(skeleton)
+	packed, report := r.contextBudget.pack(results, budget)
+	if len(report.Truncated) > 0 || len(report.Dropped) > 0 {
+		log.Printf("context budget %d tokens for model %s: truncated %v, dropped %v", report.Budget, model, report.Truncated, report.Dropped)
+	}
+	return packed, report, nil
+}
+
+// rank 用排序器（以及可选的 MMR）对检索结果重排
+func (r *RAGService) rank(ctx context.Context, query string, results []retriever.RetrievalResult, opts QueryOptions) ([]retriever.RetrievalResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	candidates := make([]ranker.Candidate, len(results))
+	byID := make(map[string]retriever.RetrievalResult, len(results))
+	for i, result := range results {
+		candidates[i] = ranker.Candidate{
+			ID:       result.Document.ID,
+			Content:  result.Document.Content,
+			Metadata: result.Document.Metadata,
+			Vector:   result.Vector,
+			Score:    result.Score,
+		}
+		byID[result.Document.ID] = result
+	}
+
+	ranked := candidates
+	if r.rankerService != nil {
+		var err error
+		ranked, err = r.rankerService.Rank(ctx, query, ranked)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.MMRLambda != nil {
+		mmr, err := ranker.NewMMRRanker(*opts.MMRLambda)
+		if err != nil {
+			return nil, err
+		}
+		ranked, err = mmr.Rank(ctx, query, ranked)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return answer, nil
+	reordered := make([]retriever.RetrievalResult, 0, len(ranked))
+	for _, c := range ranked {
+		result, ok := byID[c.ID]
+		if !ok {
+			continue
+		}
+		result.Score = c.Score
+		reordered = append(reordered, result)
+	}
+	return reordered, nil
+}
+
+// HasPromptTemplate 是否存在指定名称的提示模板，空名称表示默认模板
+func (r *RAGService) HasPromptTemplate(name string) bool {
+	return r.promptService.HasTemplate(name)
 }
 
 // AddDocuments 添加文档
@@ -0,0 +1,118 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"goRag/internal/chunker"
+	"goRag/internal/llm"
+	"goRag/internal/retriever"
+)
+
+// chunkRetriever 按固定顺序返回分块的测试检索器，Expand 按原文档去重
+type chunkRetriever struct {
+	results []retriever.RetrievalResult
+	calls   []int // 每次 Retrieve 请求的候选数
+}
+
+// newChunkRetriever 按给定顺序生成分块：parents[i] 为第 i 个结果所属的原文档
+func newChunkRetriever(parents ...string) *chunkRetriever {
+	r := &chunkRetriever{}
+	for i, parentID := range parents {
+		r.results = append(r.results, retriever.RetrievalResult{
+			Document: retriever.Document{
+				ID:       chunker.ChunkID(parentID, i),
+				Content:  parentID,
+				Metadata: map[string]interface{}{chunker.MetadataParentID: parentID},
+			},
+			Score: float64(len(parents) - i),
+		})
+	}
+	return r
+}
+
+func (r *chunkRetriever) Retrieve(ctx context.Context, query string, topK int, filter *retriever.Filter) ([]retriever.RetrievalResult, error) {
+	r.calls = append(r.calls, topK)
+	return r.results[:min(topK, len(r.results))], nil
+}
+
+func (r *chunkRetriever) AddDocuments(ctx context.Context, documents []retriever.Document) error {
+	return nil
+}
+
+func (r *chunkRetriever) DeleteDocument(ctx context.Context, documentID string) error {
+	return nil
+}
+
+func (r *chunkRetriever) Expand(ctx context.Context, results []retriever.RetrievalResult, window int) ([]retriever.RetrievalResult, error) {
+	seen := make(map[string]bool)
+	var expanded []retriever.RetrievalResult
+	for _, result := range results {
+		parentID := result.Document.Metadata[chunker.MetadataParentID].(string)
+		if seen[parentID] {
+			continue
+		}
+		seen[parentID] = true
+		expanded = append(expanded, retriever.RetrievalResult{
+			Document: retriever.Document{ID: parentID, Content: parentID},
+			Score:    result.Score,
+		})
+	}
+	return expanded, nil
+}
+
+// resultIDs 返回检索结果的文档 ID
+func resultIDs(results []retriever.RetrievalResult) string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Document.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestRetrieveRefillsTopKAfterExpand(t *testing.T) {
+	// 前 12 个分块都来自 a，之后每个原文档一个分块
+	parents := make([]string, 0, 20)
+	for i := 0; i < 12; i++ {
+		parents = append(parents, "a")
+	}
+	for i := 0; i < 8; i++ {
+		parents = append(parents, fmt.Sprintf("d%d", i))
+	}
+
+	tests := []struct {
+		name      string
+		parents   []string
+		window    int
+		topK      int
+		wantIDs   string
+		wantCalls []int
+	}{
+		{"no expansion", parents, 0, 3, "a#0,a#1,a#2", []int{9}},
+		{"refill after dedupe", parents, retriever.ExpandWhole, 3, "a,d0,d1", []int{9, 18}},
+		{"retriever exhausted", parents[:13], retriever.ExpandWhole, 3, "a,d0", []int{9, 18}},
+		{"fewer chunks than top_k", parents, 1, 30, "a,d0,d1,d2,d3,d4,d5,d6,d7", []int{90}},
+		{"gives up after max rounds", slices.Repeat([]string{"a"}, 100), 1, 2, "a", []int{6, 12, 24, 48}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newChunkRetriever(tt.parents...)
+			service := NewRAGService(nil, retriever.NewService(source), llm.NewService(llm.NewMockLLM()),
+				WithExpandWindow(tt.window))
+
+			results, err := service.retrieve(context.Background(), "query", QueryOptions{TopK: tt.topK})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resultIDs(results); got != tt.wantIDs {
+				t.Errorf("results = %s, want %s", got, tt.wantIDs)
+			}
+			if fmt.Sprint(source.calls) != fmt.Sprint(tt.wantCalls) {
+				t.Errorf("retrieve calls = %v, want %v", source.calls, tt.wantCalls)
+			}
+		})
+	}
+}
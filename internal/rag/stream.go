@@ -0,0 +1,94 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 流式查询事件类型
+const (
+	EventRetrieval = "retrieval" // 检索完成，携带参考文档
+	EventDelta     = "delta"     // LLM 生成的一段增量文本
+	EventDone      = "done"      // 生成结束，携带完整结果、引用和耗时
+)
+
+// Timing 查询各阶段耗时
+type Timing struct {
+	Retrieval  time.Duration // 检索 + 排序 + 构建提示词
+	Generation time.Duration // LLM 生成
+	Total      time.Duration
+}
+
+// StreamEvent 流式查询事件
+type StreamEvent struct {
+	Type    string       // 事件类型：EventRetrieval / EventDelta / EventDone
+	Sources []Source     // EventRetrieval：参考文档
+	Delta   string       // EventDelta：增量文本
+	Result  *QueryResult // EventDone：完整结果
+	Timing  *Timing      // EventDone：耗时
+}
+
+// errStreamStopped 事件处理函数返回错误时用于中断 LLM 生成
+var errStreamStopped = errors.New("stream stopped by handler")
+
+// QueryStream 流式查询
+// 依次发出：一个 EventRetrieval 事件、若干 EventDelta 事件、一个 EventDone 事件。
+// emit 返回错误（如客户端断开）或 ctx 被取消时立即停止生成并返回错误。
+func (r *RAGService) QueryStream(ctx context.Context, query string, opts QueryOptions, emit func(StreamEvent) error) error {
+	start := time.Now()
+
+	// 步骤 1-4：检索、排序、构建上下文和提示词
+	prepared, err := r.prepare(ctx, query, nil, opts)
+	if err != nil {
+		return err
+	}
+	timing := &Timing{Retrieval: time.Since(start)}
+
+	if err := emit(StreamEvent{Type: EventRetrieval, Sources: prepared.sources}); err != nil {
+		return err
+	}
+
+	// 没有找到相关文档时不调用 LLM
+	if prepared.skipGeneration {
+		timing.Total = time.Since(start)
+		return emit(StreamEvent{
+			Type:   EventDone,
+			Result: &QueryResult{Answer: noDocumentsAnswer},
+			Timing: timing,
+		})
+	}
+
+	// 步骤 5：流式生成，每个片段转发给调用方
+	var answer strings.Builder
+	var handlerErr error
+	generationStart := time.Now()
+	err = r.llmService.GenerateStream(ctx, prepared.messages, opts.Generation, func(delta string) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		answer.WriteString(delta)
+		if err := emit(StreamEvent{Type: EventDelta, Delta: delta}); err != nil {
+			handlerErr = err
+			return errStreamStopped
+		}
+		return nil
+	})
+	if handlerErr != nil {
+		return handlerErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate answer: %w", err)
+	}
+	timing.Generation = time.Since(generationStart)
+	timing.Total = time.Since(start)
+
+	// 步骤 6：解析引用
+	return emit(StreamEvent{
+		Type:   EventDone,
+		Result: prepared.result(answer.String()),
+		Timing: timing,
+	})
+}
@@ -0,0 +1,235 @@
+package ranker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"goRag/internal/envconfig"
+)
+
+// CrossEncoderConfig 交叉编码器重排配置
+type CrossEncoderConfig struct {
+	BaseURL   string        // 重排服务地址，默认 http://localhost:11434
+	Path      string        // 接口路径，默认 /api/rerank（Ollama 兼容）；vLLM、llama.cpp 等通用服务一般为 /v1/rerank
+	Model     string        // 重排模型名称，如 "bge-reranker-v2-m3"
+	APIKey    string        // 可选，设置后以 Bearer Token 发送
+	BatchSize int           // 每次请求最多携带的文档数
+	Timeout   time.Duration // 单次请求超时时间
+}
+
+// NewCrossEncoderConfigFromEnv 从环境变量创建配置，数值格式不正确时返回错误
+func NewCrossEncoderConfigFromEnv() (*CrossEncoderConfig, error) {
+	baseURL := os.Getenv("RERANK_BASE_URL")
+	if baseURL == "" {
+		baseURL = os.Getenv("OLLAMA_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+
+	path := os.Getenv("RERANK_PATH")
+	if path == "" {
+		path = "/api/rerank"
+	}
+
+	model := os.Getenv("RERANK_MODEL")
+	if model == "" {
+		model = "bge-reranker-v2-m3" // 默认重排模型
+	}
+
+	batchSize, err := envconfig.Int("RERANK_BATCH_SIZE", 32)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := envconfig.Duration("RERANK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CrossEncoderConfig{
+		BaseURL:   baseURL,
+		Path:      path,
+		Model:     model,
+		APIKey:    os.Getenv("RERANK_API_KEY"),
+		BatchSize: batchSize,
+		Timeout:   timeout,
+	}, nil
+}
+
+// CrossEncoderRanker 交叉编码器重排序器
+// 把 (查询, 段落) 成对发送给重排模型打分。相比向量相似度，交叉编码器同时看到查询和段落，
+// 精度更高但更慢，适合对少量候选做精排。
+// 调用失败（超时、服务不可用、响应异常）时保持原有顺序返回，不影响主流程。
+type CrossEncoderRanker struct {
+	config *CrossEncoderConfig
+	client *http.Client
+}
+
+// NewCrossEncoderRanker 创建交叉编码器重排序器
+func NewCrossEncoderRanker(config *CrossEncoderConfig) (*CrossEncoderRanker, error) {
+	if config == nil {
+		var err error
+		if config, err = NewCrossEncoderConfigFromEnv(); err != nil {
+			return nil, err
+		}
+	}
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("rerank base URL is required")
+	}
+	if config.Model == "" {
+		return nil, fmt.Errorf("rerank model is required")
+	}
+	if config.Path == "" {
+		config.Path = "/api/rerank"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 32
+	}
+
+	return &CrossEncoderRanker{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+		},
+	}, nil
+}
+
+// rerankRequest 重排接口请求结构（Cohere/Jina 风格，Ollama 兼容服务和 vLLM、llama.cpp 均支持）
+type rerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+// rerankResponse 重排接口响应结构
+type rerankResponse struct {
+	Results []struct {
+		Index          int      `json:"index"`
+		RelevanceScore *float64 `json:"relevance_score"`
+		Score          *float64 `json:"score"` // 部分服务使用 score 字段
+	} `json:"results"`
+}
+
+// Rank 对候选进行重排
+func (c *CrossEncoderRanker) Rank(ctx context.Context, query string, candidates []Candidate) ([]Candidate, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	scores := make([]float64, len(candidates))
+	for start := 0; start < len(candidates); start += c.config.BatchSize {
+		end := min(start+c.config.BatchSize, len(candidates))
+
+		batchScores, err := c.scoreBatch(ctx, query, candidates[start:end])
+		if err != nil {
+			// 请求本身被取消时向上返回，其他错误降级为原顺序
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("cross-encoder rerank failed, keeping original order: %v", err)
+			return candidates, nil
+		}
+		copy(scores[start:end], batchScores)
+	}
+
+	result := make([]Candidate, len(candidates))
+	copy(result, candidates)
+	for i := range result {
+		result[i].Score = scores[i]
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+
+	return result, nil
+}
+
+// scoreBatch 对一批候选打分，返回与输入顺序一致的分数
+func (c *CrossEncoderRanker) scoreBatch(ctx context.Context, query string, batch []Candidate) ([]float64, error) {
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+
+	documents := make([]string, len(batch))
+	for i, candidate := range batch {
+		documents[i] = candidate.Content
+	}
+
+	reqBody := rerankRequest{
+		Model:     c.config.Model,
+		Query:     query,
+		Documents: documents,
+		TopN:      len(documents),
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// 创建 HTTP 请求
+	url := strings.TrimRight(c.config.BaseURL, "/") + c.config.Path
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+
+	// 发送请求
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send rerank request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 检查状态码
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("rerank API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	// 解析响应
+	var rerankResp rerankResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rerankResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(rerankResp.Results) != len(batch) {
+		return nil, fmt.Errorf("mismatched number of rerank results: expected %d, got %d", len(batch), len(rerankResp.Results))
+	}
+
+	scores := make([]float64, len(batch))
+	seen := make([]bool, len(batch))
+	for _, r := range rerankResp.Results {
+		if r.Index < 0 || r.Index >= len(batch) || seen[r.Index] {
+			return nil, fmt.Errorf("invalid rerank result index %d", r.Index)
+		}
+		switch {
+		case r.RelevanceScore != nil:
+			scores[r.Index] = *r.RelevanceScore
+		case r.Score != nil:
+			scores[r.Index] = *r.Score
+		default:
+			return nil, fmt.Errorf("rerank result %d has no score", r.Index)
+		}
+		seen[r.Index] = true
+	}
+
+	return scores, nil
+}
@@ -0,0 +1,166 @@
+package ranker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRerankServer 模拟 /api/rerank 接口，文档 "d<n>" 的分数为 n，结果按输入的逆序返回
+// scoreField 为返回分数使用的字段名（relevance_score 或 score）
+type fakeRerankServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func newFakeRerankServer(t *testing.T, scoreField string, status int) *fakeRerankServer {
+	t.Helper()
+	s := &fakeRerankServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rerankRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.batches = append(s.batches, req.Documents)
+		s.mu.Unlock()
+
+		if status != http.StatusOK {
+			http.Error(w, "unavailable", status)
+			return
+		}
+
+		results := make([]map[string]interface{}, len(req.Documents))
+		for i, doc := range req.Documents {
+			n, _ := strconv.Atoi(strings.TrimPrefix(doc, "d"))
+			results[i] = map[string]interface{}{"index": i, scoreField: float64(n)}
+		}
+		slices.Reverse(results)
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestCrossEncoder(t *testing.T, server *fakeRerankServer, batchSize int) *CrossEncoderRanker {
+	t.Helper()
+	r, err := NewCrossEncoderRanker(&CrossEncoderConfig{
+		BaseURL:   server.URL,
+		Model:     "test-reranker",
+		BatchSize: batchSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// testCandidates 生成内容依次为 contents 的候选，ID 与内容相同
+func testCandidates(contents ...string) []Candidate {
+	candidates := make([]Candidate, len(contents))
+	for i, content := range contents {
+		candidates[i] = Candidate{ID: content, Content: content, Score: float64(len(contents) - i)}
+	}
+	return candidates
+}
+
+func candidateIDs(candidates []Candidate) []string {
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestCrossEncoderRankScoreMapping(t *testing.T) {
+	for _, field := range []string{"relevance_score", "score"} {
+		t.Run(field, func(t *testing.T) {
+			server := newFakeRerankServer(t, field, http.StatusOK)
+			r := newTestCrossEncoder(t, server, 32)
+
+			ranked, err := r.Rank(context.Background(), "q", testCandidates("d1", "d3", "d2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := candidateIDs(ranked), []string{"d3", "d2", "d1"}; !slices.Equal(got, want) {
+				t.Errorf("order = %v, want %v", got, want)
+			}
+			for _, c := range ranked {
+				if want, _ := strconv.Atoi(strings.TrimPrefix(c.ID, "d")); c.Score != float64(want) {
+					t.Errorf("%s score = %v, want %d", c.ID, c.Score, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCrossEncoderRankBatching(t *testing.T) {
+	server := newFakeRerankServer(t, "relevance_score", http.StatusOK)
+	r := newTestCrossEncoder(t, server, 2)
+
+	ranked, err := r.Rank(context.Background(), "q", testCandidates("d2", "d5", "d1", "d4", "d3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := candidateIDs(ranked), []string{"d5", "d4", "d3", "d2", "d1"}; !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+
+	want := [][]string{{"d2", "d5"}, {"d1", "d4"}, {"d3"}}
+	if len(server.batches) != len(want) {
+		t.Fatalf("got %d batches, want %d", len(server.batches), len(want))
+	}
+	for i := range want {
+		if !slices.Equal(server.batches[i], want[i]) {
+			t.Errorf("batch %d = %v, want %v", i, server.batches[i], want[i])
+		}
+	}
+}
+
+func TestCrossEncoderRankFallback(t *testing.T) {
+	tests := []struct {
+		name   string
+		server func(t *testing.T) *fakeRerankServer
+	}{
+		{"server error", func(t *testing.T) *fakeRerankServer {
+			return newFakeRerankServer(t, "relevance_score", http.StatusServiceUnavailable)
+		}},
+		{"missing score", func(t *testing.T) *fakeRerankServer {
+			return newFakeRerankServer(t, "unknown_field", http.StatusOK)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestCrossEncoder(t, tt.server(t), 32)
+			candidates := testCandidates("d1", "d3", "d2")
+
+			ranked, err := r.Rank(context.Background(), "q", candidates)
+			if err != nil {
+				t.Fatalf("expected fallback without error, got %v", err)
+			}
+			if got, want := candidateIDs(ranked), candidateIDs(candidates); !slices.Equal(got, want) {
+				t.Errorf("order = %v, want original %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCrossEncoderRankCanceled(t *testing.T) {
+	server := newFakeRerankServer(t, "relevance_score", http.StatusOK)
+	r := newTestCrossEncoder(t, server, 32)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Rank(ctx, "q", testCandidates("d1", "d2")); err == nil {
+		t.Fatal("expected context error")
+	}
+}
@@ -0,0 +1,205 @@
+package ranker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"goRag/internal/llm"
+)
+
+// judgeSystemPrompt 评分用的系统提示词，要求模型只输出 JSON
+const judgeSystemPrompt = `You are a relevance grader for a retrieval system.
+Given a user query and a candidate passage, rate how useful the passage is for answering the query
+on an integer scale from 0 (irrelevant) to 10 (directly answers the query).
+Respond with JSON only, in the form {"score": <0-10>}. Do not explain.`
+
+// judgeMaxTokens 评分回复只有一个很短的 JSON 对象
+const judgeMaxTokens = 32
+
+// LLMJudgeConfig LLM 评分排序配置
+type LLMJudgeConfig struct {
+	Workers         int // 并发评分的最大协程数
+	MaxPassageChars int // 段落截断长度（按字符），避免超长段落撑爆上下文
+}
+
+// DefaultLLMJudgeConfig 默认 LLM 评分排序配置
+func DefaultLLMJudgeConfig() LLMJudgeConfig {
+	return LLMJudgeConfig{
+		Workers:         4,
+		MaxPassageChars: 2000,
+	}
+}
+
+// LLMJudgeRanker 用大语言模型给 (查询, 段落) 打分的排序器
+// 适合没有专门重排模型的部署：任何 llm.LLM（Ollama、OpenAI、Mock）都可以作为评分器。
+// 每个候选单独调用一次模型，通过有界协程池并发执行；单个候选评分失败时记为 0 分，
+// 全部失败时保持原顺序。
+type LLMJudgeRanker struct {
+	llm    llm.LLM
+	config LLMJudgeConfig
+}
+
+// NewLLMJudgeRanker 创建 LLM 评分排序器
+func NewLLMJudgeRanker(model llm.LLM, config LLMJudgeConfig) (*LLMJudgeRanker, error) {
+	if model == nil {
+		return nil, fmt.Errorf("llm cannot be nil")
+	}
+
+	defaults := DefaultLLMJudgeConfig()
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.MaxPassageChars <= 0 {
+		config.MaxPassageChars = defaults.MaxPassageChars
+	}
+
+	return &LLMJudgeRanker{
+		llm:    model,
+		config: config,
+	}, nil
+}
+
+// Rank 并发评分后按分数降序排序
+func (j *LLMJudgeRanker) Rank(ctx context.Context, query string, candidates []Candidate) ([]Candidate, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	scores := make([]float64, len(candidates))
+	errs := make([]error, len(candidates))
+
+	// 有界协程池：jobs 通道分发下标，ctx 取消后停止分发
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(j.config.Workers, len(candidates)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				scores[i], errs[i] = j.grade(ctx, query, candidates[i].Content)
+			}
+		}()
+	}
+
+dispatch:
+	for i := range candidates {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			log.Printf("llm judge failed to grade candidate %s: %v", candidates[i].ID, err)
+		}
+	}
+	if failed == len(candidates) {
+		log.Printf("llm judge failed for all candidates, keeping original order")
+		return candidates, nil
+	}
+
+	result := make([]Candidate, len(candidates))
+	copy(result, candidates)
+	for i := range result {
+		result[i].Score = scores[i]
+	}
+	sort.SliceStable(result, func(a, b int) bool {
+		return result[a].Score > result[b].Score
+	})
+
+	return result, nil
+}
+
+// grade 调用模型给单个段落打分，返回归一化到 [0, 1] 的分数
+func (j *LLMJudgeRanker) grade(ctx context.Context, query, passage string) (float64, error) {
+	if runes := []rune(passage); len(runes) > j.config.MaxPassageChars {
+		passage = string(runes[:j.config.MaxPassageChars])
+	}
+
+	messages := []llm.Message{
+		{Role: "system", Content: judgeSystemPrompt},
+		{Role: "user", Content: fmt.Sprintf("Query: %s\n\nPassage: %s", query, passage)},
+	}
+
+	// 评分需要稳定可复现：温度为 0，并要求输出 JSON
+	temperature := 0.0
+	reply, err := j.llm.Generate(ctx, messages, llm.GenerateOptions{
+		Temperature: &temperature,
+		MaxTokens:   judgeMaxTokens,
+		JSONMode:    true,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	score, err := parseJudgeScore(reply)
+	if err != nil {
+		return 0, err
+	}
+	return score / 10, nil
+}
+
+var (
+	jsonObjectPattern = regexp.MustCompile(`(?s)\{.*?\}`)
+	numberPattern     = regexp.MustCompile(`-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?`)
+)
+
+// parseJudgeScore 从模型输出中解析 0-10 的分数
+// 依次尝试：JSON 对象中的 score/relevance 字段、第一个数字；结果截断到 [0, 10]，NaN 和 ±Inf 返回错误
+func parseJudgeScore(reply string) (float64, error) {
+	reply = strings.TrimSpace(reply)
+
+	for _, match := range jsonObjectPattern.FindAllString(reply, -1) {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(match), &obj); err != nil {
+			continue
+		}
+		for _, key := range []string{"score", "relevance", "rating"} {
+			switch v := obj[key].(type) {
+			case float64:
+				return clampScore(v)
+			case string:
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					return clampScore(f)
+				}
+			}
+		}
+	}
+
+	if match := numberPattern.FindString(reply); match != "" {
+		f, err := strconv.ParseFloat(match, 64)
+		if err != nil {
+			// 超出 float64 范围（如 1e309）
+			return 0, fmt.Errorf("invalid score %q in judge reply: %w", match, err)
+		}
+		return clampScore(f)
+	}
+
+	return 0, fmt.Errorf("no score found in judge reply %q", reply)
+}
+
+// clampScore 把分数限制在 [0, 10]，NaN 和 ±Inf 返回错误
+func clampScore(score float64) (float64, error) {
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0, fmt.Errorf("judge score %v is not a finite number", score)
+	}
+	return max(0, min(10, score)), nil
+}
@@ -0,0 +1,162 @@
+package ranker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goRag/internal/llm"
+)
+
+func TestParseJudgeScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    float64
+		wantErr bool
+	}{
+		{"json score", `{"score": 7}`, 7, false},
+		{"json string score", `{"score": "8.5"}`, 8.5, false},
+		{"json relevance key", `{"relevance": 3}`, 3, false},
+		{"json in code fence", "```json\n{\"score\": 6}\n```", 6, false},
+		{"bare number", "9", 9, false},
+		{"bare decimal", " 4.5\n", 4.5, false},
+		{"number in prose", "I would rate this passage 5 out of 10.", 5, false},
+		{"above range", `{"score": 42}`, 10, false},
+		{"below range", "-3", 0, false},
+		{"no number", "The passage is relevant.", 0, true},
+		{"empty", "", 0, true},
+		{"json NaN string", `{"score": "NaN"}`, 0, true},
+		{"json Inf string", `{"score": "+Inf"}`, 0, true},
+		{"overflowing number", "1e309", 0, true},
+		{"overflowing json number", `{"score": 1e309}`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJudgeScore(tt.reply)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseJudgeScore(%q) = %v, want error", tt.reply, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJudgeScore(%q) error: %v", tt.reply, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseJudgeScore(%q) = %v, want %v", tt.reply, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeJudgeLLM 按段落内容返回预设回复，并记录最大并发数
+type fakeJudgeLLM struct {
+	replies map[string]string // 段落 -> 回复，缺少时返回错误
+
+	mu            sync.Mutex
+	active, peak  int
+	calls         int
+	jsonModeCalls int
+}
+
+func (f *fakeJudgeLLM) Generate(ctx context.Context, messages []llm.Message, opts llm.GenerateOptions) (string, error) {
+	f.mu.Lock()
+	f.active++
+	f.calls++
+	f.peak = max(f.peak, f.active)
+	if opts.JSONMode {
+		f.jsonModeCalls++
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.active--
+		f.mu.Unlock()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	_, passage, _ := strings.Cut(messages[len(messages)-1].Content, "Passage: ")
+	reply, ok := f.replies[passage]
+	if !ok {
+		return "", fmt.Errorf("judge unavailable")
+	}
+	return reply, nil
+}
+
+func (f *fakeJudgeLLM) GenerateStream(ctx context.Context, messages []llm.Message, opts llm.GenerateOptions, callback func(string) error) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f *fakeJudgeLLM) GetModelName() string {
+	return "fake-judge"
+}
+
+func TestLLMJudgeRankerWorkerPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies map[string]string
+		want    []string
+	}{
+		{
+			name: "sorted by judge score",
+			replies: map[string]string{
+				"d1": `{"score": 2}`, "d2": `{"score": 9}`, "d3": "5", "d4": `{"score": 7}`, "d5": "0",
+			},
+			want: []string{"d2", "d4", "d3", "d1", "d5"},
+		},
+		{
+			name: "failed candidates score 0",
+			replies: map[string]string{
+				"d1": `{"score": 2}`, "d3": "NaN", "d4": `{"score": 7}`,
+			},
+			want: []string{"d4", "d1", "d2", "d3", "d5"},
+		},
+		{
+			name:    "all failed keeps original order",
+			replies: map[string]string{},
+			want:    []string{"d1", "d2", "d3", "d4", "d5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			judge := &fakeJudgeLLM{replies: tt.replies}
+			r, err := NewLLMJudgeRanker(judge, LLMJudgeConfig{Workers: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ranked, err := r.Rank(context.Background(), "q", testCandidates("d1", "d2", "d3", "d4", "d5"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := candidateIDs(ranked); !slices.Equal(ids, tt.want) {
+				t.Errorf("order = %v, want %v", ids, tt.want)
+			}
+			if judge.calls != 5 || judge.jsonModeCalls != 5 {
+				t.Errorf("calls = %d (json mode %d), want 5", judge.calls, judge.jsonModeCalls)
+			}
+			if judge.peak > 2 {
+				t.Errorf("peak concurrency = %d, want <= 2", judge.peak)
+			}
+		})
+	}
+}
+
+func TestLLMJudgeRankerCanceled(t *testing.T) {
+	judge := &fakeJudgeLLM{replies: map[string]string{"d1": "1"}}
+	r, err := NewLLMJudgeRanker(judge, LLMJudgeConfig{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Rank(ctx, "q", testCandidates("d1", "d2", "d3")); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,115 @@
+package ranker
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// MMRRanker 最大边际相关性（Maximal Marginal Relevance）排序器
+// 逐个挑选候选，每一步选择使下式最大的候选：
+//
+//	λ * 相关性(d) - (1 - λ) * max(与已选候选的相似度)
+//
+// λ = 1 时等价于按相关性排序，λ 越小越偏向多样性。相关性使用候选分数（min-max 归一化），
+// 相似度使用候选向量的余弦相似度；候选缺少向量时保持原顺序。
+// 分数为 NaN 或 ±Inf 的候选按原顺序排在最后，向量含 NaN 时不参与相似度计算。
+type MMRRanker struct {
+	lambda float64
+}
+
+// NewMMRRanker 创建 MMR 排序器，lambda 取值 [0, 1]
+func NewMMRRanker(lambda float64) (*MMRRanker, error) {
+	if lambda < 0 || lambda > 1 || math.IsNaN(lambda) {
+		return nil, fmt.Errorf("mmr lambda must be in [0, 1], got %v", lambda)
+	}
+	return &MMRRanker{
+		lambda: lambda,
+	}, nil
+}
+
+// Rank 按 MMR 顺序重排全部候选
+// 输出分数为每个候选被选中时的 MMR 得分
+func (m *MMRRanker) Rank(ctx context.Context, query string, candidates []Candidate) ([]Candidate, error) {
+	if len(candidates) <= 1 {
+		return candidates, nil
+	}
+	for _, c := range candidates {
+		if len(c.Vector) == 0 {
+			return candidates, nil
+		}
+	}
+
+	relevance := normalizeScores(candidates)
+
+	// maxSim[i] 记录候选 i 与已选集合的最大相似度
+	maxSim := make([]float64, len(candidates))
+	selected := make([]bool, len(candidates))
+	result := make([]Candidate, 0, len(candidates))
+
+	for len(result) < len(candidates) {
+		best := -1
+		bestScore := math.Inf(-1)
+		for i := range candidates {
+			if selected[i] {
+				continue
+			}
+			score := m.lambda * relevance[i]
+			if len(result) > 0 {
+				score -= (1 - m.lambda) * maxSim[i]
+			}
+			if math.IsNaN(score) {
+				score = math.Inf(-1)
+			}
+			// 得分都是 -Inf 时退回第一个未选中的候选
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		selected[best] = true
+		chosen := candidates[best]
+		chosen.Score = bestScore
+		result = append(result, chosen)
+
+		for i := range candidates {
+			if selected[i] {
+				continue
+			}
+			if sim := cosineSimilarity(candidates[i].Vector, candidates[best].Vector); !math.IsNaN(sim) {
+				maxSim[i] = max(maxSim[i], sim)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// normalizeScores 把候选分数 min-max 归一化到 [0, 1]，分数都相同时记为 1
+// NaN 和 ±Inf 不参与计算范围，归一化结果记为 -Inf
+func normalizeScores(candidates []Candidate) []float64 {
+	minScore, maxScore := math.Inf(1), math.Inf(-1)
+	for _, c := range candidates {
+		if isFinite(c.Score) {
+			minScore = min(minScore, c.Score)
+			maxScore = max(maxScore, c.Score)
+		}
+	}
+
+	normalized := make([]float64, len(candidates))
+	for i, c := range candidates {
+		if !isFinite(c.Score) {
+			normalized[i] = math.Inf(-1)
+		} else if maxScore == minScore {
+			normalized[i] = 1
+		} else {
+			normalized[i] = (c.Score - minScore) / (maxScore - minScore)
+		}
+	}
+	return normalized
+}
+
+// isFinite 判断分数既不是 NaN 也不是 ±Inf
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
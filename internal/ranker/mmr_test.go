@@ -0,0 +1,111 @@
+package ranker
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestMMRRankerRank(t *testing.T) {
+	// a 和 b 方向相同，c 与它们正交
+	tests := []struct {
+		name       string
+		lambda     float64
+		candidates []Candidate
+		want       []string
+	}{
+		{
+			name:   "lambda 1 keeps relevance order",
+			lambda: 1,
+			candidates: []Candidate{
+				{ID: "c", Vector: []float32{0, 1}, Score: 0.5},
+				{ID: "a", Vector: []float32{1, 0}, Score: 0.9},
+				{ID: "b", Vector: []float32{1, 0}, Score: 0.8},
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name:   "lambda 0 only looks at diversity",
+			lambda: 0,
+			candidates: []Candidate{
+				{ID: "a", Vector: []float32{1, 0}, Score: 0.9},
+				{ID: "b", Vector: []float32{1, 0}, Score: 0.8},
+				{ID: "c", Vector: []float32{0, 1}, Score: 0.5},
+			},
+			want: []string{"a", "c", "b"},
+		},
+		{
+			name:   "duplicate vectors are pushed down",
+			lambda: 0.5,
+			candidates: []Candidate{
+				{ID: "a", Vector: []float32{1, 0}, Score: 0.9},
+				{ID: "b", Vector: []float32{1, 0}, Score: 0.85},
+				{ID: "c", Vector: []float32{0, 1}, Score: 0.5},
+			},
+			want: []string{"a", "c", "b"},
+		},
+		{
+			name:   "missing vector keeps original order",
+			lambda: 0.5,
+			candidates: []Candidate{
+				{ID: "b", Vector: []float32{1, 0}, Score: 0.8},
+				{ID: "a", Score: 0.9},
+				{ID: "c", Vector: []float32{0, 1}, Score: 0.5},
+			},
+			want: []string{"b", "a", "c"},
+		},
+		{
+			name:   "NaN score goes last",
+			lambda: 0.5,
+			candidates: []Candidate{
+				{ID: "n", Vector: []float32{0, 1}, Score: math.NaN()},
+				{ID: "a", Vector: []float32{1, 0}, Score: 0.9},
+				{ID: "c", Vector: []float32{0, 1}, Score: 0.5},
+			},
+			want: []string{"a", "c", "n"},
+		},
+		{
+			name:   "all scores NaN fall back to input order",
+			lambda: 1,
+			candidates: []Candidate{
+				{ID: "a", Vector: []float32{1, 0}, Score: math.NaN()},
+				{ID: "b", Vector: []float32{0, 1}, Score: math.NaN()},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name:   "NaN vector does not panic",
+			lambda: 0.5,
+			candidates: []Candidate{
+				{ID: "a", Vector: []float32{1, 0}, Score: 0.9},
+				{ID: "n", Vector: []float32{float32(math.NaN()), 0}, Score: 0.8},
+				{ID: "c", Vector: []float32{0, 1}, Score: 0.5},
+			},
+			want: []string{"a", "n", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewMMRRanker(tt.lambda)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.Rank(context.Background(), "q", tt.candidates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := candidateIDs(got); !slices.Equal(ids, tt.want) {
+				t.Errorf("order = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMMRRankerRejectsInvalidLambda(t *testing.T) {
+	for _, lambda := range []float64{-0.1, 1.1, math.NaN()} {
+		if _, err := NewMMRRanker(lambda); err == nil {
+			t.Errorf("NewMMRRanker(%v) expected error", lambda)
+		}
+	}
+}
@@ -2,21 +2,80 @@ package ranker
 
 import (
 	"context"
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
+
+	"goRag/internal/llm"
 )
 
-// RankedItem 排序项
+// RankedItem 排序项（只有 ID 和分数）
 type RankedItem struct {
 	ID    string
 	Score float64
 }
 
+// Candidate 排序候选，携带排序可能用到的全部信息
+type Candidate struct {
+	ID       string
+	Content  string
+	Metadata map[string]interface{}
+	Vector   []float32 // 文档的嵌入向量，可能为空
+	Score    float64   // 上一阶段（检索或前一个排序器）给出的分数
+}
+
 // Ranker 排序器接口
+// 可以看到查询和候选文档的内容、元数据、向量，用于交叉编码器、多样性过滤等需要内容的排序
 type Ranker interface {
+	// Rank 对候选进行排序（也可以过滤），返回新的候选列表
+	Rank(ctx context.Context, query string, candidates []Candidate) ([]Candidate, error)
+}
+
+// ScoreRanker 只基于分数的排序器接口
+// SimpleRanker、BM25Ranker 等实现这个接口，通过 AdaptScoreRanker 接入 Ranker
+type ScoreRanker interface {
 	// Rank 对结果进行排序
 	Rank(ctx context.Context, items []RankedItem) ([]RankedItem, error)
 }
 
+// scoreRankerAdapter 把 ScoreRanker 适配为 Ranker
+type scoreRankerAdapter struct {
+	ranker ScoreRanker
+}
+
+// AdaptScoreRanker 把只看分数的排序器适配为 Ranker
+// 排序结果按 ID 映射回原候选，并用新分数替换原分数
+func AdaptScoreRanker(r ScoreRanker) Ranker {
+	return &scoreRankerAdapter{ranker: r}
+}
+
+// Rank 对候选进行排序
+func (a *scoreRankerAdapter) Rank(ctx context.Context, query string, candidates []Candidate) ([]Candidate, error) {
+	items := make([]RankedItem, len(candidates))
+	byID := make(map[string]Candidate, len(candidates))
+	for i, c := range candidates {
+		items[i] = RankedItem{ID: c.ID, Score: c.Score}
+		byID[c.ID] = c
+	}
+
+	ranked, err := a.ranker.Rank(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Candidate, 0, len(ranked))
+	for _, item := range ranked {
+		c, ok := byID[item.ID]
+		if !ok {
+			continue
+		}
+		c.Score = item.Score
+		result = append(result, c)
+	}
+	return result, nil
+}
+
 // Service 排序服务
 type Service struct {
 	ranker Ranker
@@ -30,8 +89,8 @@ func NewService(ranker Ranker) *Service {
 }
 
 // Rank 排序结果
-func (s *Service) Rank(ctx context.Context, items []RankedItem) ([]RankedItem, error) {
-	return s.ranker.Rank(ctx, items)
+func (s *Service) Rank(ctx context.Context, query string, candidates []Candidate) ([]Candidate, error) {
+	return s.ranker.Rank(ctx, query, candidates)
 }
 
 // SimpleRanker 简单排序器（按分数降序）
@@ -53,3 +112,108 @@ func (r *SimpleRanker) Rank(ctx context.Context, items []RankedItem) ([]RankedIt
 
 	return result, nil
 }
+
+// Chain 排序链，按顺序依次应用多个排序器
+type Chain struct {
+	rankers []Ranker
+}
+
+// NewChain 创建排序链
+func NewChain(rankers ...Ranker) *Chain {
+	return &Chain{
+		rankers: rankers,
+	}
+}
+
+// Rank 依次调用每个排序器，前一个的输出作为后一个的输入
+func (c *Chain) Rank(ctx context.Context, query string, candidates []Candidate) ([]Candidate, error) {
+	var err error
+	for _, r := range c.rankers {
+		candidates, err = r.Rank(ctx, query, candidates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return candidates, nil
+}
+
+// ParseChain 根据配置字符串创建排序器
+// 格式为逗号分隔的排序器名称，可带冒号参数，例如：
+//
+//	"simple"
+//	"rerank:0.3"        // 阈值 0.3 的 Reranker
+//	"rerank:0.3:0.9"    // 向量相似度 >= 0.9 的候选视为重复
+//	"bm25:1.2:0.75,rerank"
+//	"rerank,cross-encoder" // 交叉编码器配置从 RERANK_* 环境变量读取
+//	"llm-judge:8"          // 使用 judge 模型评分，8 个并发
+//	"rerank,mmr:0.7"       // 重排后做 MMR 多样化
+//
+// judge 仅在配置了 llm-judge 时使用，可以为 nil。
+// 只有一个排序器时直接返回它，多个时返回 Chain
+func ParseChain(spec string, judge llm.LLM) (Ranker, error) {
+	var rankers []Ranker
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		fields := strings.Split(part, ":")
+		params := make([]float64, len(fields)-1)
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter %q for ranker %q", field, fields[0])
+			}
+			params[i] = v
+		}
+		param := func(i int, def float64) float64 {
+			if i < len(params) {
+				return params[i]
+			}
+			return def
+		}
+
+		switch fields[0] {
+		case "simple":
+			rankers = append(rankers, AdaptScoreRanker(NewSimpleRanker()))
+		case "rerank":
+			reranker := NewReranker(param(0, 0))
+			reranker.duplicateThreshold = param(1, reranker.duplicateThreshold)
+			rankers = append(rankers, reranker)
+		case "bm25":
+			rankers = append(rankers, AdaptScoreRanker(NewBM25Ranker(param(0, 1.2), param(1, 0.75))))
+		case "cross-encoder":
+			crossEncoder, err := NewCrossEncoderRanker(nil)
+			if err != nil {
+				return nil, err
+			}
+			rankers = append(rankers, crossEncoder)
+		case "mmr":
+			mmr, err := NewMMRRanker(param(0, 0.5))
+			if err != nil {
+				return nil, err
+			}
+			rankers = append(rankers, mmr)
+		case "llm-judge":
+			config := DefaultLLMJudgeConfig()
+			config.Workers = int(param(0, float64(config.Workers)))
+			judgeRanker, err := NewLLMJudgeRanker(judge, config)
+			if err != nil {
+				return nil, err
+			}
+			rankers = append(rankers, judgeRanker)
+		default:
+			return nil, fmt.Errorf("unknown ranker %q", fields[0])
+		}
+	}
+
+	switch len(rankers) {
+	case 0:
+		return nil, fmt.Errorf("no ranker specified")
+	case 1:
+		return rankers[0], nil
+	default:
+		return NewChain(rankers...), nil
+	}
+}
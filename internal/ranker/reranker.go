@@ -4,72 +4,119 @@ import (
 	"context"
 	"math"
 	"sort"
+	"strings"
 )
 
 // Reranker 重排序器（基于交叉编码器思想）
 // 使用更复杂的排序算法，考虑多个因素
 type Reranker struct {
-	// 可以添加权重配置等
-	scoreThreshold float64
+	scoreThreshold     float64 // 低于该分数的候选被过滤
+	duplicateThreshold float64 // 两个候选向量余弦相似度达到该值视为重复
 }
 
 // NewReranker 创建重排序器
 func NewReranker(scoreThreshold float64) *Reranker {
 	return &Reranker{
-		scoreThreshold: scoreThreshold,
+		scoreThreshold:     scoreThreshold,
+		duplicateThreshold: 0.95,
 	}
 }
 
 // Rank 对结果进行重排序
 // 使用更复杂的排序策略：分数、长度、多样性等
-func (r *Reranker) Rank(ctx context.Context, items []RankedItem) ([]RankedItem, error) {
-	if len(items) == 0 {
-		return items, nil
+func (r *Reranker) Rank(ctx context.Context, query string, candidates []Candidate) ([]Candidate, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
 	}
 
-	result := make([]RankedItem, 0, len(items))
-	
+	result := make([]Candidate, 0, len(candidates))
+
 	// 过滤低分项
-	for _, item := range items {
-		if item.Score >= r.scoreThreshold {
-			result = append(result, item)
+	for _, c := range candidates {
+		if c.Score >= r.scoreThreshold {
+			result = append(result, c)
 		}
 	}
 
 	// 按分数降序排序
-	sort.Slice(result, func(i, j int) bool {
-		// 可以添加其他排序因素
-		// 例如：多样性、长度等
+	sort.SliceStable(result, func(i, j int) bool {
 		return result[i].Score > result[j].Score
 	})
 
-	// 应用多样性过滤（简单实现：去重相似结果）
+	// 应用多样性过滤（去掉重复或近似重复的结果）
 	result = r.diversityFilter(result)
 
 	return result, nil
 }
 
-// diversityFilter 多样性过滤（简单实现）
-func (r *Reranker) diversityFilter(items []RankedItem) []RankedItem {
-	if len(items) <= 1 {
-		return items
+// diversityFilter 多样性过滤
+// 按分数从高到低保留候选，与已保留候选 ID 相同、内容相同或向量过于相似的候选被丢弃
+func (r *Reranker) diversityFilter(candidates []Candidate) []Candidate {
+	if len(candidates) <= 1 {
+		return candidates
 	}
 
-	filtered := make([]RankedItem, 0, len(items))
-	seen := make(map[string]bool)
+	filtered := make([]Candidate, 0, len(candidates))
+	seenIDs := make(map[string]bool)
+	seenContent := make(map[string]bool)
 
-	for _, item := range items {
-		// 简单的去重（实际可以使用更复杂的相似度计算）
-		if !seen[item.ID] {
-			seen[item.ID] = true
-			filtered = append(filtered, item)
+	for _, c := range candidates {
+		if seenIDs[c.ID] {
+			continue
+		}
+		content := strings.Join(strings.Fields(c.Content), " ")
+		if content != "" && seenContent[content] {
+			continue
+		}
+		if r.nearDuplicate(c, filtered) {
+			continue
 		}
+
+		seenIDs[c.ID] = true
+		if content != "" {
+			seenContent[content] = true
+		}
+		filtered = append(filtered, c)
 	}
 
 	return filtered
 }
 
+// nearDuplicate 判断候选是否与已保留的候选向量过于相似
+func (r *Reranker) nearDuplicate(c Candidate, kept []Candidate) bool {
+	if len(c.Vector) == 0 || r.duplicateThreshold <= 0 {
+		return false
+	}
+	for _, k := range kept {
+		if len(k.Vector) > 0 && cosineSimilarity(c.Vector, k.Vector) >= r.duplicateThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// cosineSimilarity 计算余弦相似度
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dotProduct, normA, normB float64
+	for i := range a {
+		dotProduct += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
 // BM25Ranker BM25 风格的排序器
+// 只能基于已有分数做缩放；需要真正按词频和文档长度打分时使用 retriever.BM25Index
 type BM25Ranker struct {
 	k1 float64 // 词频饱和度参数
 	b  float64 // 长度归一化参数
@@ -121,4 +168,3 @@ func (b *BM25Ranker) Rank(ctx context.Context, items []RankedItem) ([]RankedItem
 
 	return result, nil
 }
-
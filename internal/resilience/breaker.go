@@ -0,0 +1,146 @@
+package resilience
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态，请求被直接拒绝
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State 熔断器状态
+type State string
+
+const (
+	StateClosed   State = "closed"    // 正常放行
+	StateOpen     State = "open"      // 熔断，直接拒绝请求
+	StateHalfOpen State = "half_open" // 熔断超时后放行一个探测请求
+)
+
+// Status 熔断器状态快照（用于健康检查）
+type Status struct {
+	Name      string     `json:"name"`
+	State     State      `json:"state"`
+	Failures  int        `json:"consecutive_failures"`
+	OpenUntil *time.Time `json:"open_until,omitempty"` // 打开状态下下一次放行探测请求的时间
+}
+
+// Breaker 熔断器
+// 连续失败达到阈值后打开，打开期间直接返回 ErrCircuitOpen；超过 openTimeout 后进入半开状态，
+// 只放行一个探测请求：成功则关闭，失败则重新打开。threshold <= 0 时始终关闭。
+type Breaker struct {
+	name        string
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool // 半开状态下是否已有探测请求在进行
+}
+
+// NewBreaker 创建熔断器
+func NewBreaker(name string, threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		name:        name,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		state:       StateClosed,
+	}
+}
+
+// Name 返回熔断器名称
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Allow 判断是否放行请求，放行后调用方必须调用 Success、Failure 或 Release 之一
+func (b *Breaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, b.name)
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, b.name)
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success 记录一次成功，半开状态下关闭熔断器
+func (b *Breaker) Success() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != StateClosed {
+		log.Printf("circuit breaker %s closed", b.name)
+	}
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure 记录一次失败，连续失败达到阈值或半开探测失败时打开熔断器
+func (b *Breaker) Failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.threshold) {
+		log.Printf("circuit breaker %s opened after %d consecutive failures", b.name, b.failures)
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release 放弃一次已放行的请求（如调用方主动取消），不改变状态
+func (b *Breaker) Release() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Status 返回当前状态快照
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := Status{Name: b.name, State: b.state, Failures: b.failures}
+	if b.state == StateOpen {
+		openUntil := b.openedAt.Add(b.openTimeout)
+		status.OpenUntil = &openUntil
+	}
+	return status
+}
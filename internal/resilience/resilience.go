@@ -0,0 +1,195 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"goRag/internal/envconfig"
+)
+
+// Config 重试和熔断配置
+// 零值表示不重试、不熔断，与直接使用 http.DefaultTransport 的行为一致。
+type Config struct {
+	MaxRetries       int           // 最大重试次数（不含首次请求）
+	InitialBackoff   time.Duration // 首次重试前的等待时间，之后每次翻倍
+	MaxBackoff       time.Duration // 单次等待时间上限；Retry-After 超过该值时不再重试
+	FailureThreshold int           // 连续失败多少次后熔断，<= 0 时不熔断
+	OpenTimeout      time.Duration // 熔断后多久放行一个探测请求
+}
+
+// DefaultConfig 默认重试和熔断配置
+func DefaultConfig() Config {
+	return Config{
+		MaxRetries:       3,
+		InitialBackoff:   500 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+// NewConfigFromEnv 从环境变量创建配置，未设置的项使用默认值，格式不正确时返回错误
+func NewConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	var errs [5]error
+	config.MaxRetries, errs[0] = envconfig.Int("RAG_RETRY_MAX", config.MaxRetries)
+	config.InitialBackoff, errs[1] = envconfig.Duration("RAG_RETRY_BACKOFF", config.InitialBackoff)
+	config.MaxBackoff, errs[2] = envconfig.Duration("RAG_RETRY_MAX_BACKOFF", config.MaxBackoff)
+	config.FailureThreshold, errs[3] = envconfig.Int("RAG_BREAKER_THRESHOLD", config.FailureThreshold)
+	config.OpenTimeout, errs[4] = envconfig.Duration("RAG_BREAKER_TIMEOUT", config.OpenTimeout)
+	return config, errors.Join(errs[:]...)
+}
+
+// Transport 带重试和熔断的 http.RoundTripper
+//
+// 以下情况按指数退避（带随机抖动）重试：
+//   - 429 和 5xx 响应，响应中有 Retry-After 时按其等待
+//   - 连接被重置、被拒绝或连接中途断开
+//
+// 请求体必须可以重放（http.NewRequest 对 bytes.Buffer/bytes.Reader/strings.Reader 会自动设置 GetBody），
+// 否则不重试。重试只发生在收到响应头之前，流式响应开始后不会重试。
+// 重试用尽后仍是连接错误或 5xx 时计为一次失败，连续失败达到阈值后熔断，熔断期间直接返回 ErrCircuitOpen。
+// 注意 http.Client.Timeout 包含全部重试和等待时间。
+type Transport struct {
+	name    string
+	config  Config
+	base    http.RoundTripper
+	breaker *Breaker
+}
+
+// NewTransport 创建带重试和熔断的 Transport，name 用于日志和健康检查，base 为 nil 时使用 http.DefaultTransport
+func NewTransport(name string, config Config, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		name:    name,
+		config:  config,
+		base:    base,
+		breaker: NewBreaker(name, config.FailureThreshold, config.OpenTimeout),
+	}
+}
+
+// Breaker 返回该 Transport 使用的熔断器
+func (t *Transport) Breaker() *Breaker {
+	return t.breaker
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				t.breaker.Release()
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		retryable := isRetryableError(err) || (err == nil && isRetryableStatus(resp.StatusCode))
+		if !retryable || !replayable || attempt >= t.config.MaxRetries {
+			t.record(resp, err)
+			return resp, err
+		}
+
+		wait := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if retryAfter > t.config.MaxBackoff {
+					// 服务端要求等待的时间太长，直接把响应交给调用方
+					t.record(resp, err)
+					return resp, err
+				}
+				wait = retryAfter
+			}
+			// 丢弃响应体以便复用连接
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			t.record(nil, req.Context().Err())
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// record 根据最终结果更新熔断器：连接错误、超时和 5xx 计为失败，调用方主动取消不计，其余（包括 429 和 4xx）计为成功
+func (t *Transport) record(resp *http.Response, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		t.breaker.Release()
+	case err != nil, resp.StatusCode >= 500:
+		t.breaker.Failure()
+	default:
+		t.breaker.Success()
+	}
+}
+
+// backoff 计算第 attempt 次重试前的等待时间：指数增长，取上限后在 [d/2, d] 内随机抖动
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := t.config.InitialBackoff
+	for i := 0; i < attempt && d < t.config.MaxBackoff; i++ {
+		d *= 2
+	}
+	if t.config.MaxBackoff > 0 && d > t.config.MaxBackoff {
+		d = t.config.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// isRetryableStatus 429 和 5xx 可以重试（501 表示不支持，重试无意义）
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || (status >= 500 && status != http.StatusNotImplemented)
+}
+
+// isRetryableError 连接被重置、被拒绝或中途断开可以重试，超时和取消不重试
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或 HTTP 日期）
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
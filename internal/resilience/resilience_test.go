@@ -0,0 +1,316 @@
+package resilience
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeRoundTripper 依次返回预设的状态码，记录收到的请求体
+type fakeRoundTripper struct {
+	mu       sync.Mutex
+	statuses []int
+	headers  []http.Header
+	errs     []error
+	bodies   []string
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	attempt := len(f.bodies)
+	body := ""
+	if req.Body != nil {
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+	}
+	f.bodies = append(f.bodies, body)
+
+	if attempt < len(f.errs) && f.errs[attempt] != nil {
+		return nil, f.errs[attempt]
+	}
+	status := http.StatusOK
+	if attempt < len(f.statuses) {
+		status = f.statuses[attempt]
+	}
+	header := http.Header{}
+	if attempt < len(f.headers) && f.headers[attempt] != nil {
+		header = f.headers[attempt]
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeRoundTripper) attempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.bodies)
+}
+
+func testConfig() Config {
+	return Config{
+		MaxRetries:       3,
+		InitialBackoff:   time.Millisecond,
+		MaxBackoff:       10 * time.Millisecond,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Hour,
+	}
+}
+
+func newTestRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("POST", "http://example.invalid/api", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"empty", "", false, 0, 0},
+		{"seconds", "5", true, 5 * time.Second, 5 * time.Second},
+		{"zero seconds", "0", true, 0, 0},
+		{"negative seconds", "-1", false, 0, 0},
+		{"http date", future, true, 28 * time.Second, 30 * time.Second},
+		{"http date in the past", past, true, 0, 0},
+		{"invalid", "soon", false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("parseRetryAfter(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			}
+			if got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %v, want in [%v, %v]", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestTransportRetriesRetryableStatus(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			base := &fakeRoundTripper{statuses: []int{status, status, http.StatusOK}}
+			transport := NewTransport("test", testConfig(), base)
+
+			resp, err := transport.RoundTrip(newTestRequest(t, "payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+			if base.attempts() != 3 {
+				t.Errorf("attempts = %d, want 3", base.attempts())
+			}
+			for i, body := range base.bodies {
+				if body != "payload" {
+					t.Errorf("attempt %d body = %q, want the replayed payload", i, body)
+				}
+			}
+		})
+	}
+}
+
+func TestTransportRetriesConnectionErrors(t *testing.T) {
+	base := &fakeRoundTripper{errs: []error{syscall.ECONNRESET, io.ErrUnexpectedEOF}}
+	transport := NewTransport("test", testConfig(), base)
+
+	resp, err := transport.RoundTrip(newTestRequest(t, "payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || base.attempts() != 3 {
+		t.Errorf("status = %d after %d attempts, want 200 after 3", resp.StatusCode, base.attempts())
+	}
+}
+
+func TestTransportDoesNotRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    *fakeRoundTripper
+		request func(t *testing.T) *http.Request
+		status  int
+	}{
+		{
+			name:    "client error",
+			base:    &fakeRoundTripper{statuses: []int{http.StatusBadRequest}},
+			request: func(t *testing.T) *http.Request { return newTestRequest(t, "payload") },
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "not implemented",
+			base:    &fakeRoundTripper{statuses: []int{http.StatusNotImplemented}},
+			request: func(t *testing.T) *http.Request { return newTestRequest(t, "payload") },
+			status:  http.StatusNotImplemented,
+		},
+		{
+			name: "retry-after exceeds max backoff",
+			base: &fakeRoundTripper{
+				statuses: []int{http.StatusTooManyRequests},
+				headers:  []http.Header{{"Retry-After": []string{"60"}}},
+			},
+			request: func(t *testing.T) *http.Request { return newTestRequest(t, "payload") },
+			status:  http.StatusTooManyRequests,
+		},
+		{
+			name: "non-replayable body",
+			base: &fakeRoundTripper{statuses: []int{http.StatusServiceUnavailable}},
+			request: func(t *testing.T) *http.Request {
+				req := newTestRequest(t, "payload")
+				req.Body = io.NopCloser(strings.NewReader("payload"))
+				req.GetBody = nil
+				return req
+			},
+			status: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewTransport("test", testConfig(), tt.base)
+			resp, err := transport.RoundTrip(tt.request(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.base.attempts() != 1 {
+				t.Errorf("attempts = %d, want 1", tt.base.attempts())
+			}
+		})
+	}
+}
+
+func TestTransportGivesUpAfterMaxRetries(t *testing.T) {
+	base := &fakeRoundTripper{statuses: []int{503, 503, 503, 503, 503}}
+	transport := NewTransport("test", testConfig(), base)
+
+	resp, err := transport.RoundTrip(newTestRequest(t, "payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || base.attempts() != 4 {
+		t.Errorf("status = %d after %d attempts, want 503 after 4", resp.StatusCode, base.attempts())
+	}
+	if failures := transport.Breaker().Status().Failures; failures != 1 {
+		t.Errorf("exhausted retries should count as one failure, got %d", failures)
+	}
+}
+
+func TestTransportOpensBreaker(t *testing.T) {
+	config := testConfig()
+	config.MaxRetries = 0
+	base := &fakeRoundTripper{statuses: []int{500, 500, 200}}
+	transport := NewTransport("test", config, base)
+
+	for i := 0; i < 2; i++ {
+		if _, err := transport.RoundTrip(newTestRequest(t, "payload")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := transport.RoundTrip(newTestRequest(t, "payload"))
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if base.attempts() != 2 {
+		t.Errorf("open breaker should not reach the backend, attempts = %d", base.attempts())
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	const openTimeout = 20 * time.Millisecond
+	b := NewBreaker("test", 2, openTimeout)
+
+	// closed：失败未达阈值时继续放行，成功后计数清零
+	b.Allow()
+	b.Failure()
+	b.Allow()
+	b.Success()
+	if s := b.Status(); s.State != StateClosed || s.Failures != 0 {
+		t.Fatalf("status = %+v, want closed with 0 failures", s)
+	}
+
+	// 连续失败达到阈值后打开
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.Failure()
+	}
+	if s := b.Status(); s.State != StateOpen || s.OpenUntil == nil {
+		t.Fatalf("status = %+v, want open with OpenUntil", s)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open breaker Allow = %v, want ErrCircuitOpen", err)
+	}
+
+	// 超时后半开，只放行一个探测请求
+	time.Sleep(openTimeout + 10*time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe should be allowed after timeout: %v", err)
+	}
+	if s := b.Status(); s.State != StateHalfOpen {
+		t.Fatalf("state = %s, want half_open", s.State)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second concurrent probe Allow = %v, want ErrCircuitOpen", err)
+	}
+
+	// 探测被放弃后可以再放行一个
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe should be allowed after Release: %v", err)
+	}
+
+	// 探测失败重新打开
+	b.Failure()
+	if s := b.Status(); s.State != StateOpen {
+		t.Fatalf("state = %s after failed probe, want open", s.State)
+	}
+
+	// 探测成功后关闭
+	time.Sleep(openTimeout + 10*time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.Success()
+	if s := b.Status(); s.State != StateClosed || s.Failures != 0 {
+		t.Fatalf("status = %+v after successful probe, want closed", s)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := NewBreaker("test", 0, time.Hour)
+	for i := 0; i < 10; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.Failure()
+	}
+	if s := b.Status(); s.State != StateClosed {
+		t.Errorf("state = %s, want closed when threshold <= 0", s.State)
+	}
+}
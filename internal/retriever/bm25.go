@@ -0,0 +1,172 @@
+package retriever
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"goRag/internal/tokenizer"
+)
+
+// BM25Index BM25 倒排索引
+// 记录每个词在每篇文档中的词频和文档长度，按标准 BM25 公式打分：
+//
+//	score(q, d) = Σ IDF(t) * tf(t,d) * (k1 + 1) / (tf(t,d) + k1 * (1 - b + b * |d| / avgdl))
+//
+// 与向量检索互补：精确的标识符、人名、型号等在嵌入空间里容易被"平均掉"，关键词检索能直接命中。
+type BM25Index struct {
+	mu        sync.RWMutex
+	k1        float64 // 词频饱和度参数
+	b         float64 // 长度归一化参数
+	tokenizer tokenizer.Tokenizer
+	postings  map[string]map[string]int // 词 -> 文档 ID -> 词频
+	docTerms  map[string][]string       // 文档 ID -> 去重后的词（删除时用）
+	docLen    map[string]int            // 文档 ID -> 词数
+	totalLen  int
+}
+
+// NewBM25Index 创建 BM25 索引，非法参数回退到常用默认值 k1=1.2, b=0.75
+// 默认分词：中文双字切分并过滤常用停用词
+func NewBM25Index(k1, b float64) *BM25Index {
+	return NewBM25IndexWithTokenizer(k1, b, tokenizer.NewCJKTokenizer(tokenizer.Config{
+		CJKMode:   tokenizer.ModeBigram,
+		StopWords: tokenizer.DefaultStopWords(),
+	}))
+}
+
+// NewBM25IndexWithTokenizer 使用指定分词器创建 BM25 索引
+func NewBM25IndexWithTokenizer(k1, b float64, tok tokenizer.Tokenizer) *BM25Index {
+	if k1 <= 0 {
+		k1 = 1.2
+	}
+	if b < 0 || b > 1 {
+		b = 0.75
+	}
+	return &BM25Index{
+		k1:        k1,
+		b:         b,
+		tokenizer: tok,
+		postings:  make(map[string]map[string]int),
+		docTerms:  make(map[string][]string),
+		docLen:    make(map[string]int),
+	}
+}
+
+// Add 索引一篇文档，ID 已存在时替换
+func (x *BM25Index) Add(id string, text string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(id)
+
+	terms := x.tokenizer.Tokenize(text)
+	freq := make(map[string]int)
+	for _, term := range terms {
+		freq[term]++
+	}
+
+	unique := make([]string, 0, len(freq))
+	for term, tf := range freq {
+		docs, ok := x.postings[term]
+		if !ok {
+			docs = make(map[string]int)
+			x.postings[term] = docs
+		}
+		docs[id] = tf
+		unique = append(unique, term)
+	}
+
+	x.docTerms[id] = unique
+	x.docLen[id] = len(terms)
+	x.totalLen += len(terms)
+}
+
+// Remove 从索引中删除一篇文档
+func (x *BM25Index) Remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+}
+
+// remove 删除文档（调用方需持有写锁）
+func (x *BM25Index) remove(id string) {
+	terms, ok := x.docTerms[id]
+	if !ok {
+		return
+	}
+	for _, term := range terms {
+		docs := x.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.totalLen -= x.docLen[id]
+	delete(x.docTerms, id)
+	delete(x.docLen, id)
+}
+
+// Search 返回 BM25 得分最高的 k 篇文档（按分数降序）
+// accept 不为 nil 时只返回 accept 返回 true 的 ID
+func (x *BM25Index) Search(query string, k int, accept func(id string) bool) []SearchHit {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	n := len(x.docLen)
+	if n == 0 || k <= 0 {
+		return nil
+	}
+	avgLen := float64(x.totalLen) / float64(n)
+	if avgLen == 0 {
+		avgLen = 1
+	}
+
+	// 查询词去重，只需遍历包含查询词的文档
+	seen := make(map[string]bool)
+	scores := make(map[string]float64)
+	for _, term := range x.tokenizer.Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		docs := x.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (float64(n)-df+0.5)/(df+0.5))
+
+		for id, tf := range docs {
+			if accept != nil && !accept(id) {
+				continue
+			}
+			f := float64(tf)
+			norm := 1 - x.b + x.b*float64(x.docLen[id])/avgLen
+			scores[id] += idf * f * (x.k1 + 1) / (f + x.k1*norm)
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, SearchHit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	if k < len(hits) {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// Len 返回索引中的文档数量
+func (x *BM25Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docLen)
+}
@@ -0,0 +1,216 @@
+package retriever
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// 过滤操作符
+const (
+	FilterEq     = "eq"     // 字段等于 Value
+	FilterIn     = "in"     // 字段等于 Values 中任意一个
+	FilterRange  = "range"  // 数值字段落在 [Gte/Gt, Lte/Lt] 范围内
+	FilterExists = "exists" // 字段存在
+	FilterAnd    = "and"    // 所有子条件都满足
+	FilterOr     = "or"     // 任一子条件满足
+	FilterNot    = "not"    // 子条件不满足
+)
+
+// Filter 元数据过滤表达式
+// 以树形结构表示，可以直接从 JSON 解析，例如：
+//
+//	{"op": "eq", "field": "category", "value": "编程语言"}
+//	{"op": "and", "filters": [
+//	    {"op": "in", "field": "source", "values": ["技术文档", "AI技术"]},
+//	    {"op": "range", "field": "year", "gte": 2000}
+//	]}
+type Filter struct {
+	Op      string        `json:"op"`
+	Field   string        `json:"field,omitempty"`
+	Value   interface{}   `json:"value,omitempty"`
+	Values  []interface{} `json:"values,omitempty"`
+	Gt      *float64      `json:"gt,omitempty"`
+	Gte     *float64      `json:"gte,omitempty"`
+	Lt      *float64      `json:"lt,omitempty"`
+	Lte     *float64      `json:"lte,omitempty"`
+	Filters []*Filter     `json:"filters,omitempty"`
+}
+
+// Eq 字段等于指定值
+func Eq(field string, value interface{}) *Filter {
+	return &Filter{Op: FilterEq, Field: field, Value: value}
+}
+
+// In 字段等于任意一个指定值
+func In(field string, values ...interface{}) *Filter {
+	return &Filter{Op: FilterIn, Field: field, Values: values}
+}
+
+// Range 数值字段落在闭区间 [min, max] 内，nil 表示不限
+func Range(field string, min, max *float64) *Filter {
+	return &Filter{Op: FilterRange, Field: field, Gte: min, Lte: max}
+}
+
+// Exists 字段存在
+func Exists(field string) *Filter {
+	return &Filter{Op: FilterExists, Field: field}
+}
+
+// And 所有条件都满足
+func And(filters ...*Filter) *Filter {
+	return &Filter{Op: FilterAnd, Filters: filters}
+}
+
+// Or 任一条件满足
+func Or(filters ...*Filter) *Filter {
+	return &Filter{Op: FilterOr, Filters: filters}
+}
+
+// Not 条件取反
+func Not(filter *Filter) *Filter {
+	return &Filter{Op: FilterNot, Filters: []*Filter{filter}}
+}
+
+// Validate 校验表达式结构是否合法
+func (f *Filter) Validate() error {
+	if f == nil {
+		return nil
+	}
+
+	switch f.Op {
+	case FilterEq, FilterExists:
+		if f.Field == "" {
+			return fmt.Errorf("filter %q requires a field", f.Op)
+		}
+	case FilterIn:
+		if f.Field == "" {
+			return fmt.Errorf("filter %q requires a field", f.Op)
+		}
+		if len(f.Values) == 0 {
+			return fmt.Errorf("filter %q requires at least one value", f.Op)
+		}
+	case FilterRange:
+		if f.Field == "" {
+			return fmt.Errorf("filter %q requires a field", f.Op)
+		}
+		if f.Gt == nil && f.Gte == nil && f.Lt == nil && f.Lte == nil {
+			return fmt.Errorf("filter %q requires at least one bound", f.Op)
+		}
+	case FilterAnd, FilterOr:
+		if len(f.Filters) == 0 {
+			return fmt.Errorf("filter %q requires at least one sub-filter", f.Op)
+		}
+		for _, sub := range f.Filters {
+			if sub == nil {
+				return fmt.Errorf("filter %q contains a null sub-filter", f.Op)
+			}
+			if err := sub.Validate(); err != nil {
+				return err
+			}
+		}
+	case FilterNot:
+		if len(f.Filters) != 1 || f.Filters[0] == nil {
+			return fmt.Errorf("filter %q requires exactly one sub-filter", f.Op)
+		}
+		return f.Filters[0].Validate()
+	default:
+		return fmt.Errorf("unknown filter op %q", f.Op)
+	}
+	return nil
+}
+
+// Match 判断元数据是否满足过滤条件，nil 过滤器匹配所有文档
+func (f *Filter) Match(metadata map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+
+	switch f.Op {
+	case FilterEq:
+		value, ok := metadata[f.Field]
+		return ok && valuesEqual(value, f.Value)
+	case FilterIn:
+		value, ok := metadata[f.Field]
+		if !ok {
+			return false
+		}
+		for _, candidate := range f.Values {
+			if valuesEqual(value, candidate) {
+				return true
+			}
+		}
+		return false
+	case FilterRange:
+		n, ok := toFloat(metadata[f.Field])
+		if !ok {
+			return false
+		}
+		return (f.Gt == nil || n > *f.Gt) &&
+			(f.Gte == nil || n >= *f.Gte) &&
+			(f.Lt == nil || n < *f.Lt) &&
+			(f.Lte == nil || n <= *f.Lte)
+	case FilterExists:
+		_, ok := metadata[f.Field]
+		return ok
+	case FilterAnd:
+		for _, sub := range f.Filters {
+			if !sub.Match(metadata) {
+				return false
+			}
+		}
+		return true
+	case FilterOr:
+		for _, sub := range f.Filters {
+			if sub.Match(metadata) {
+				return true
+			}
+		}
+		return false
+	case FilterNot:
+		return len(f.Filters) == 1 && !f.Filters[0].Match(metadata)
+	default:
+		return false
+	}
+}
+
+// valuesEqual 比较两个元数据值
+// 数值统一按 float64 比较（JSON 解码后的数字都是 float64，而代码里写入的可能是 int）
+func valuesEqual(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat 将数值类型转换为 float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
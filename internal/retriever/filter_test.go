@@ -0,0 +1,193 @@
+package retriever
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	"goRag/internal/embedding"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestFilterMatch(t *testing.T) {
+	metadata := map[string]interface{}{
+		"category": "编程语言",
+		"year":     2009,         // 代码中写入的 int
+		"rating":   float64(4.5), // JSON 解码得到的 float64
+		"tags":     []interface{}{"go", "backend"},
+	}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   bool
+	}{
+		{"nil matches all", nil, true},
+		{"eq string", Eq("category", "编程语言"), true},
+		{"eq string mismatch", Eq("category", "数据库"), false},
+		{"eq int against float64", Eq("year", float64(2009)), true},
+		{"eq float64 against int", Eq("rating", 4.5), true},
+		{"eq missing field", Eq("author", "x"), false},
+		{"eq slice", Eq("tags", []interface{}{"go", "backend"}), true},
+		{"in", In("category", "数据库", "编程语言"), true},
+		{"in numeric", In("year", 2008, 2009.0), true},
+		{"in mismatch", In("category", "数据库"), false},
+		{"range int within float bounds", Range("year", floatPtr(2000), floatPtr(2010)), true},
+		{"range inclusive bound", Range("year", floatPtr(2009), nil), true},
+		{"range exclusive bound", &Filter{Op: FilterRange, Field: "year", Gt: floatPtr(2009)}, false},
+		{"range lt", &Filter{Op: FilterRange, Field: "rating", Lt: floatPtr(5)}, true},
+		{"range on string field", Range("category", floatPtr(0), nil), false},
+		{"range on missing field", Range("pages", floatPtr(0), nil), false},
+		{"exists", Exists("tags"), true},
+		{"exists missing", Exists("author"), false},
+		{"not", Not(Eq("category", "数据库")), true},
+		{"not exists", Not(Exists("author")), true},
+		{"and", And(Eq("category", "编程语言"), Range("year", floatPtr(2000), nil)), true},
+		{"and one false", And(Eq("category", "编程语言"), Range("year", nil, floatPtr(2000))), false},
+		{"or", Or(Eq("category", "数据库"), Exists("tags")), true},
+		{"or none", Or(Eq("category", "数据库"), Exists("author")), false},
+		{"nested tree", And(
+			Or(Eq("category", "数据库"), In("year", 2009)),
+			Not(And(Exists("author"), Eq("rating", 4.5))),
+		), true},
+		{"unknown op", &Filter{Op: "like", Field: "category"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(metadata); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterFromJSON(t *testing.T) {
+	data := `{"op": "and", "filters": [
+		{"op": "in", "field": "source", "values": ["技术文档", "AI技术"]},
+		{"op": "range", "field": "year", "gte": 2000},
+		{"op": "not", "filters": [{"op": "exists", "field": "draft"}]}
+	]}`
+	var filter Filter
+	if err := json.Unmarshal([]byte(data), &filter); err != nil {
+		t.Fatal(err)
+	}
+	if err := filter.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		metadata map[string]interface{}
+		want     bool
+	}{
+		{map[string]interface{}{"source": "技术文档", "year": 2020}, true},
+		{map[string]interface{}{"source": "AI技术", "year": int64(2000)}, true},
+		{map[string]interface{}{"source": "AI技术", "year": 1999}, false},
+		{map[string]interface{}{"source": "博客", "year": 2020}, false},
+		{map[string]interface{}{"source": "技术文档", "year": 2020, "draft": true}, false},
+	}
+	for _, tt := range tests {
+		if got := filter.Match(tt.metadata); got != tt.want {
+			t.Errorf("Match(%v) = %v, want %v", tt.metadata, got, tt.want)
+		}
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *Filter
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"valid tree", And(Eq("a", 1), Or(In("b", "x"), Not(Exists("c")))), false},
+		{"unknown op", &Filter{Op: "like", Field: "a"}, true},
+		{"empty op", &Filter{}, true},
+		{"eq without field", &Filter{Op: FilterEq, Value: 1}, true},
+		{"exists without field", &Filter{Op: FilterExists}, true},
+		{"in without values", &Filter{Op: FilterIn, Field: "a"}, true},
+		{"range without bounds", &Filter{Op: FilterRange, Field: "a"}, true},
+		{"range without field", &Filter{Op: FilterRange, Gte: floatPtr(1)}, true},
+		{"and without sub-filters", And(), true},
+		{"or with null sub-filter", Or(Eq("a", 1), nil), true},
+		{"not with two sub-filters", &Filter{Op: FilterNot, Filters: []*Filter{Exists("a"), Exists("b")}}, true},
+		{"not with null sub-filter", Not(nil), true},
+		{"invalid nested filter", And(Eq("a", 1), Not(&Filter{Op: FilterIn, Field: "b"})), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilterAppliedByIndexes(t *testing.T) {
+	ctx := context.Background()
+	var documents []Document
+	var want []string
+	for i := 0; i < 40; i++ {
+		category := "go"
+		if i%2 == 1 {
+			category = "python"
+		}
+		year := 2000 + i/2
+		id := fmt.Sprintf("doc-%02d", i)
+		documents = append(documents, Document{
+			ID:       id,
+			Content:  fmt.Sprintf("document %d about %s programming in %d", i, category, year),
+			Metadata: map[string]interface{}{"category": category, "year": year},
+		})
+		if category == "go" && year >= 2015 {
+			want = append(want, id)
+		}
+	}
+	filter := And(Eq("category", "go"), Range("year", floatPtr(2015), nil))
+
+	indexes := map[string]VectorIndex{
+		"flat": NewFlatIndex(),
+		"hnsw": NewHNSWIndex(DefaultHNSWConfig()),
+	}
+	for name, index := range indexes {
+		t.Run(name, func(t *testing.T) {
+			m, err := NewMemoryRetriever(embedding.NewSimpleEmbedder(32), WithIndex(index))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.AddDocuments(ctx, documents); err != nil {
+				t.Fatal(err)
+			}
+
+			// topK 大于匹配的文档数时应返回全部匹配文档，且只返回匹配文档
+			results, err := m.Retrieve(ctx, "go programming", len(documents), filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := resultIDs(results)
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("filtered results = %v, want %v", got, want)
+			}
+
+			// topK 小于匹配数时过滤在取 topK 之前生效，不会因为不匹配的文档占位而少返回
+			results, err = m.Retrieve(ctx, "python programming", 3, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 3 {
+				t.Errorf("got %d results, want 3", len(results))
+			}
+			for _, r := range results {
+				if !filter.Match(r.Document.Metadata) {
+					t.Errorf("document %s does not match the filter", r.Document.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package retriever
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// HNSWConfig HNSW 索引配置
+type HNSWConfig struct {
+	M              int   // 每个节点在每层的最大邻居数（第 0 层为 2*M），越大召回越高、内存越多
+	EfConstruction int   // 构建时的候选集大小，越大图质量越好、插入越慢
+	EfSearch       int   // 查询时的候选集大小，越大召回越高、查询越慢
+	Seed           int64 // 随机层级生成的种子，固定后构建结果可复现
+}
+
+// DefaultHNSWConfig 默认 HNSW 配置
+func DefaultHNSWConfig() HNSWConfig {
+	return HNSWConfig{
+		M:              16,
+		EfConstruction: 200,
+		EfSearch:       64,
+		Seed:           42,
+	}
+}
+
+// hnswNode 图中的一个节点
+type hnswNode struct {
+	id        string
+	vector    []float32 // 已归一化，内积即余弦相似度
+	neighbors [][]int   // 每层的邻居下标
+	deleted   bool
+}
+
+// HNSWIndex 基于 HNSW（Hierarchical Navigable Small World）图的近似最近邻索引
+//
+// 原理：
+//   - 每个节点随机分配一个层级，层级越高节点越少，形成"跳表"式的多层图
+//   - 查询时从最高层的入口点开始贪心下降，逐层缩小范围，最后在第 0 层做 ef 宽度的搜索
+//   - 查询复杂度约为 O(log n)，代价是结果是近似的（召回率通常 > 95%）
+//
+// 删除采用墓碑标记：被删节点仍参与导航但不出现在结果中，墓碑超过一半时整体重建。
+// 元数据过滤与墓碑同样处理，在取 topK 之前生效。
+type HNSWIndex struct {
+	mu        sync.RWMutex
+	config    HNSWConfig
+	nodes     []*hnswNode
+	ids       map[string]int // 文档 ID -> 节点下标（仅包含未删除的节点）
+	entry     int            // 入口点下标，-1 表示空图
+	maxLevel  int
+	deleted   int
+	levelMult float64
+	rng       *rand.Rand
+}
+
+// NewHNSWIndex 创建 HNSW 索引，非法参数会回退到默认值
+func NewHNSWIndex(config HNSWConfig) *HNSWIndex {
+	defaults := DefaultHNSWConfig()
+	if config.M < 2 {
+		config.M = defaults.M
+	}
+	if config.EfConstruction < config.M {
+		config.EfConstruction = defaults.EfConstruction
+	}
+	if config.EfSearch <= 0 {
+		config.EfSearch = defaults.EfSearch
+	}
+
+	return &HNSWIndex{
+		config:    config,
+		ids:       make(map[string]int),
+		entry:     -1,
+		levelMult: 1 / math.Log(float64(config.M)),
+		rng:       rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+// Add 插入一个向量，ID 已存在时替换旧向量
+func (h *HNSWIndex) Add(id string, vector []float32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.ids[id]; ok {
+		h.remove(id)
+	}
+	h.insert(id, normalize(vector))
+}
+
+// Remove 删除一个向量
+func (h *HNSWIndex) Remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(id)
+	if h.deleted > 64 && h.deleted > len(h.ids) {
+		h.rebuild()
+	}
+}
+
+// Search 近似查找与 query 最相似的 k 个向量
+func (h *HNSWIndex) Search(query []float32, k int, accept func(id string) bool) []SearchHit {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	q := normalize(query)
+
+	// 从最高层贪心下降到第 1 层
+	ep := h.entry
+	for level := h.maxLevel; level > 0; level-- {
+		ep = h.searchLayer(q, []int{ep}, 1, level, nil)[0].idx
+	}
+
+	// 在第 0 层做宽度为 ef 的搜索
+	// 墓碑和不满足过滤条件的节点照常参与导航，但不进入结果集
+	allowed := func(node *hnswNode) bool {
+		return !node.deleted && (accept == nil || accept(node.id))
+	}
+	candidates := h.searchLayer(q, []int{ep}, max(h.config.EfSearch, k), 0, allowed)
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	hits := make([]SearchHit, len(candidates))
+	for i, c := range candidates {
+		hits[i] = SearchHit{ID: h.nodes[c.idx].id, Score: 1 - c.dist}
+	}
+	return hits
+}
+
+// SetEfSearch 调整查询时的候选集大小，用于在召回率和延迟之间权衡
+func (h *HNSWIndex) SetEfSearch(ef int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ef > 0 {
+		h.config.EfSearch = ef
+	}
+}
+
+// Len 返回索引中的向量数量
+func (h *HNSWIndex) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.ids)
+}
+
+// insert 插入节点（调用方需持有写锁）
+func (h *HNSWIndex) insert(id string, vector []float32) {
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+	idx := len(h.nodes)
+	node := &hnswNode{
+		id:        id,
+		vector:    vector,
+		neighbors: make([][]int, level+1),
+	}
+	h.nodes = append(h.nodes, node)
+	h.ids[id] = idx
+
+	if h.entry < 0 {
+		h.entry = idx
+		h.maxLevel = level
+		return
+	}
+
+	// 高于新节点层级的部分只做贪心下降
+	ep := []int{h.entry}
+	for l := h.maxLevel; l > level; l-- {
+		ep = []int{h.searchLayer(vector, ep, 1, l, nil)[0].idx}
+	}
+
+	// 在新节点所在的每一层建立双向连接
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, ep, h.config.EfConstruction, l, nil)
+		node.neighbors[l] = h.selectNeighbors(candidates, h.config.M)
+
+		maxConn := h.maxConnections(l)
+		for _, n := range node.neighbors[l] {
+			neighbor := h.nodes[n]
+			neighbor.neighbors[l] = append(neighbor.neighbors[l], idx)
+			if len(neighbor.neighbors[l]) > maxConn {
+				h.shrink(neighbor, l, maxConn)
+			}
+		}
+
+		ep = ep[:0]
+		for _, c := range candidates {
+			ep = append(ep, c.idx)
+		}
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entry = idx
+	}
+}
+
+// remove 标记删除（调用方需持有写锁）
+func (h *HNSWIndex) remove(id string) {
+	idx, ok := h.ids[id]
+	if !ok {
+		return
+	}
+	h.nodes[idx].deleted = true
+	delete(h.ids, id)
+	h.deleted++
+
+	if len(h.ids) == 0 {
+		h.nodes = nil
+		h.entry = -1
+		h.maxLevel = 0
+		h.deleted = 0
+	}
+}
+
+// rebuild 丢弃墓碑节点并重新构图（调用方需持有写锁）
+func (h *HNSWIndex) rebuild() {
+	live := make([]*hnswNode, 0, len(h.ids))
+	for _, node := range h.nodes {
+		if !node.deleted {
+			live = append(live, node)
+		}
+	}
+
+	h.nodes = nil
+	h.ids = make(map[string]int, len(live))
+	h.entry = -1
+	h.maxLevel = 0
+	h.deleted = 0
+	for _, node := range live {
+		h.insert(node.id, node.vector)
+	}
+}
+
+// maxConnections 返回某层允许的最大邻居数
+func (h *HNSWIndex) maxConnections(level int) int {
+	if level == 0 {
+		return 2 * h.config.M
+	}
+	return h.config.M
+}
+
+// shrink 邻居超出上限时用启发式重新挑选
+func (h *HNSWIndex) shrink(node *hnswNode, level, maxConn int) {
+	candidates := make([]distItem, len(node.neighbors[level]))
+	for i, n := range node.neighbors[level] {
+		candidates[i] = distItem{idx: n, dist: distance(node.vector, h.nodes[n].vector)}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+	node.neighbors[level] = h.selectNeighbors(candidates, maxConn)
+}
+
+// selectNeighbors 启发式邻居选择（论文算法 4）
+// 优先保留"方向不同"的邻居：候选点离基准点比离任何已选邻居都近时才选中，
+// 这样图在聚簇数据上也能保持连通；名额不足时再用被跳过的最近候选补齐。
+// candidates 需按距离升序排列。
+func (h *HNSWIndex) selectNeighbors(candidates []distItem, m int) []int {
+	selected := make([]int, 0, m)
+	skipped := make([]int, 0, len(candidates))
+
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		good := true
+		for _, s := range selected {
+			if distance(h.nodes[c.idx].vector, h.nodes[s].vector) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c.idx)
+		} else {
+			skipped = append(skipped, c.idx)
+		}
+	}
+
+	for _, idx := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, idx)
+	}
+	return selected
+}
+
+// searchLayer 在指定层做宽度为 ef 的最佳优先搜索，返回按距离升序排列的结果
+// allowed 不为 nil 时只有满足条件的节点进入结果集，其余节点仍用于扩展候选
+func (h *HNSWIndex) searchLayer(query []float32, entryPoints []int, ef, level int, allowed func(*hnswNode) bool) []distItem {
+	visited := make([]bool, len(h.nodes))
+	candidates := &minDistHeap{}
+	results := &maxDistHeap{}
+
+	for _, ep := range entryPoints {
+		visited[ep] = true
+		item := distItem{idx: ep, dist: distance(query, h.nodes[ep].vector)}
+		heap.Push(candidates, item)
+		if allowed == nil || allowed(h.nodes[ep]) {
+			heap.Push(results, item)
+			if results.Len() > ef {
+				heap.Pop(results)
+			}
+		}
+	}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(distItem)
+		if results.Len() >= ef && current.dist > (*results)[0].dist {
+			break
+		}
+
+		node := h.nodes[current.idx]
+		if level >= len(node.neighbors) {
+			continue
+		}
+		for _, n := range node.neighbors[level] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			d := distance(query, h.nodes[n].vector)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, distItem{idx: n, dist: d})
+				if allowed == nil || allowed(h.nodes[n]) {
+					heap.Push(results, distItem{idx: n, dist: d})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+
+	sorted := make([]distItem, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(distItem)
+	}
+	return sorted
+}
+
+// distance 余弦距离（输入已归一化）
+func distance(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 1
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return 1 - dot
+}
+
+// normalize 返回 L2 归一化后的向量副本
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	result := make([]float32, len(vector))
+	if norm == 0 {
+		return result
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range vector {
+		result[i] = float32(float64(v) / norm)
+	}
+	return result
+}
+
+// distItem 带距离的节点下标
+type distItem struct {
+	idx  int
+	dist float64
+}
+
+// minDistHeap 按距离升序的小顶堆（候选集）
+type minDistHeap []distItem
+
+func (h minDistHeap) Len() int            { return len(h) }
+func (h minDistHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h minDistHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minDistHeap) Push(x interface{}) { *h = append(*h, x.(distItem)) }
+func (h *minDistHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// maxDistHeap 按距离降序的大顶堆（结果集，堆顶是最远的结果）
+type maxDistHeap []distItem
+
+func (h maxDistHeap) Len() int            { return len(h) }
+func (h maxDistHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h maxDistHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxDistHeap) Push(x interface{}) { *h = append(*h, x.(distItem)) }
+func (h *maxDistHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,150 @@
+package retriever
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// randomVectors 生成 n 个 dim 维的随机向量，种子固定保证结果可复现
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+func TestHNSWRecallAgainstFlat(t *testing.T) {
+	const (
+		n       = 2000
+		dim     = 32
+		queries = 50
+		k       = 10
+	)
+
+	flat := NewFlatIndex()
+	hnsw := NewHNSWIndex(DefaultHNSWConfig())
+	for i, vector := range randomVectors(n, dim, 1) {
+		id := fmt.Sprintf("doc-%d", i)
+		flat.Add(id, vector)
+		hnsw.Add(id, vector)
+	}
+
+	found, total := 0, 0
+	for _, query := range randomVectors(queries, dim, 2) {
+		exact := make(map[string]bool, k)
+		for _, hit := range flat.Search(query, k, nil) {
+			exact[hit.ID] = true
+		}
+		for _, hit := range hnsw.Search(query, k, nil) {
+			if exact[hit.ID] {
+				found++
+			}
+		}
+		total += k
+	}
+
+	recall := float64(found) / float64(total)
+	if recall < 0.9 {
+		t.Errorf("recall@%d = %.3f, want >= 0.9", k, recall)
+	}
+}
+
+func TestHNSWRecallWithFilterAndDeletes(t *testing.T) {
+	const (
+		n   = 1000
+		dim = 16
+		k   = 5
+	)
+
+	flat := NewFlatIndex()
+	hnsw := NewHNSWIndex(DefaultHNSWConfig())
+	for i, vector := range randomVectors(n, dim, 3) {
+		id := fmt.Sprintf("doc-%d", i)
+		flat.Add(id, vector)
+		hnsw.Add(id, vector)
+	}
+	// 删除前 100 个，只接受偶数编号
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("doc-%d", i)
+		flat.Remove(id)
+		hnsw.Remove(id)
+	}
+	even := make(map[string]bool)
+	for i := 0; i < n; i += 2 {
+		even[fmt.Sprintf("doc-%d", i)] = true
+	}
+	accept := func(id string) bool { return even[id] }
+
+	found, total := 0, 0
+	for _, query := range randomVectors(20, dim, 4) {
+		exact := make(map[string]bool, k)
+		for _, hit := range flat.Search(query, k, accept) {
+			exact[hit.ID] = true
+		}
+		for _, hit := range hnsw.Search(query, k, accept) {
+			if !even[hit.ID] {
+				t.Fatalf("filtered document %s returned", hit.ID)
+			}
+			if exact[hit.ID] {
+				found++
+			}
+		}
+		total += k
+	}
+
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Errorf("filtered recall@%d = %.3f, want >= 0.9", k, recall)
+	}
+}
+
+func TestIndexSearchNonPositiveK(t *testing.T) {
+	indexes := map[string]VectorIndex{
+		"flat": NewFlatIndex(),
+		"hnsw": NewHNSWIndex(DefaultHNSWConfig()),
+	}
+	for name, index := range indexes {
+		index.Add("a", []float32{1, 0})
+		index.Add("b", []float32{0, 1})
+		for _, k := range []int{0, -1} {
+			if hits := index.Search([]float32{1, 0}, k, nil); len(hits) != 0 {
+				t.Errorf("%s: Search with k=%d returned %d hits, want 0", name, k, len(hits))
+			}
+		}
+	}
+
+	bm25 := NewBM25Index(0, 0)
+	bm25.Add("a", "hello world")
+	if hits := bm25.Search("hello", -1, nil); len(hits) != 0 {
+		t.Errorf("bm25: Search with k=-1 returned %d hits, want 0", len(hits))
+	}
+}
+
+func BenchmarkHNSWSearch(b *testing.B) {
+	benchmarkSearch(b, NewHNSWIndex(DefaultHNSWConfig()))
+}
+
+func BenchmarkFlatSearch(b *testing.B) {
+	benchmarkSearch(b, NewFlatIndex())
+}
+
+func benchmarkSearch(b *testing.B, index VectorIndex) {
+	const (
+		n   = 10000
+		dim = 64
+	)
+	for i, vector := range randomVectors(n, dim, 1) {
+		index.Add(fmt.Sprintf("doc-%d", i), vector)
+	}
+	queries := randomVectors(100, dim, 2)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Search(queries[i%len(queries)], 10, nil)
+	}
+}
@@ -0,0 +1,211 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// 融合策略
+const (
+	FusionRRF      = "rrf"      // 倒数排名融合：只看名次，不受两路分数尺度差异影响
+	FusionWeighted = "weighted" // 加权求和：两路分数先做 min-max 归一化再按权重相加
+)
+
+// 两种融合策略的分数都除以可能的最大值，归一化到 [0, 1]：两路都排第一（或都是最高分）的文档得 1 分，
+// 这样 rerank:<阈值> 等按分数过滤的排序器在混合检索下也有意义。
+
+// LexicalRetriever 同时支持向量检索和关键词检索的检索器
+type LexicalRetriever interface {
+	Retriever
+
+	// RetrieveLexical 关键词检索
+	RetrieveLexical(ctx context.Context, query string, topK int, filter *Filter) ([]RetrievalResult, error)
+}
+
+// HybridConfig 混合检索配置
+type HybridConfig struct {
+	Fusion              string  // 融合策略：FusionRRF 或 FusionWeighted
+	VectorWeight        float64 // 向量检索权重
+	LexicalWeight       float64 // 关键词检索权重
+	RRFK                int     // RRF 平滑常数，通常取 60
+	CandidateMultiplier int     // 每路召回 topK * CandidateMultiplier 个候选再融合
+}
+
+// DefaultHybridConfig 默认混合检索配置
+func DefaultHybridConfig() HybridConfig {
+	return HybridConfig{
+		Fusion:              FusionRRF,
+		VectorWeight:        1,
+		LexicalWeight:       1,
+		RRFK:                60,
+		CandidateMultiplier: 4,
+	}
+}
+
+// HybridRetriever 混合检索器
+// 分别做向量检索和 BM25 关键词检索，再把两路结果融合排序
+type HybridRetriever struct {
+	base   LexicalRetriever
+	config HybridConfig
+}
+
+// NewHybridRetriever 创建混合检索器
+func NewHybridRetriever(base LexicalRetriever, config HybridConfig) (*HybridRetriever, error) {
+	if base == nil {
+		return nil, fmt.Errorf("base retriever cannot be nil")
+	}
+
+	defaults := DefaultHybridConfig()
+	if config.Fusion == "" {
+		config.Fusion = defaults.Fusion
+	}
+	if config.Fusion != FusionRRF && config.Fusion != FusionWeighted {
+		return nil, fmt.Errorf("unknown fusion strategy %q", config.Fusion)
+	}
+	if config.VectorWeight < 0 || config.LexicalWeight < 0 {
+		return nil, fmt.Errorf("fusion weights cannot be negative")
+	}
+	if config.VectorWeight == 0 && config.LexicalWeight == 0 {
+		config.VectorWeight = defaults.VectorWeight
+		config.LexicalWeight = defaults.LexicalWeight
+	}
+	if config.RRFK <= 0 {
+		config.RRFK = defaults.RRFK
+	}
+	if config.CandidateMultiplier <= 0 {
+		config.CandidateMultiplier = defaults.CandidateMultiplier
+	}
+
+	return &HybridRetriever{
+		base:   base,
+		config: config,
+	}, nil
+}
+
+// Retrieve 混合检索
+func (h *HybridRetriever) Retrieve(ctx context.Context, query string, topK int, filter *Filter) ([]RetrievalResult, error) {
+	if topK <= 0 {
+		return nil, nil
+	}
+	candidates := topK * h.config.CandidateMultiplier
+
+	vectorResults, err := h.base.Retrieve(ctx, query, candidates, filter)
+	if err != nil {
+		return nil, fmt.Errorf("vector retrieval failed: %w", err)
+	}
+	lexicalResults, err := h.base.RetrieveLexical(ctx, query, candidates, filter)
+	if err != nil {
+		return nil, fmt.Errorf("lexical retrieval failed: %w", err)
+	}
+
+	var fused []RetrievalResult
+	if h.config.Fusion == FusionWeighted {
+		fused = weightedFusion(vectorResults, lexicalResults, h.config.VectorWeight, h.config.LexicalWeight)
+	} else {
+		fused = reciprocalRankFusion(vectorResults, lexicalResults, h.config.VectorWeight, h.config.LexicalWeight, h.config.RRFK)
+	}
+
+	if topK < len(fused) {
+		fused = fused[:topK]
+	}
+	return fused, nil
+}
+
+// Expand 底层检索器支持扩展时委托给它，否则原样返回
+func (h *HybridRetriever) Expand(ctx context.Context, results []RetrievalResult, window int) ([]RetrievalResult, error) {
+	if expander, ok := h.base.(Expander); ok {
+		return expander.Expand(ctx, results, window)
+	}
+	return results, nil
+}
+
+// AddDocuments 添加文档（向量索引和关键词索引由底层检索器同步维护）
+func (h *HybridRetriever) AddDocuments(ctx context.Context, documents []Document) error {
+	return h.base.AddDocuments(ctx, documents)
+}
+
+// DeleteDocument 删除文档
+func (h *HybridRetriever) DeleteDocument(ctx context.Context, documentID string) error {
+	return h.base.DeleteDocument(ctx, documentID)
+}
+
+// reciprocalRankFusion 倒数排名融合：score(d) = Σ w / (k + rank)，再除以最大值 Σ w / (k + 1)
+func reciprocalRankFusion(vector, lexical []RetrievalResult, vectorWeight, lexicalWeight float64, k int) []RetrievalResult {
+	fused := newFusion()
+	maxScore := (vectorWeight + lexicalWeight) / float64(k+1)
+	for rank, result := range vector {
+		fused.add(result, vectorWeight/float64(k+rank+1)/maxScore)
+	}
+	for rank, result := range lexical {
+		fused.add(result, lexicalWeight/float64(k+rank+1)/maxScore)
+	}
+	return fused.sorted()
+}
+
+// weightedFusion 加权求和：两路分数各自 min-max 归一化到 [0, 1] 后加权相加，再除以权重之和
+func weightedFusion(vector, lexical []RetrievalResult, vectorWeight, lexicalWeight float64) []RetrievalResult {
+	fused := newFusion()
+	totalWeight := vectorWeight + lexicalWeight
+	for i, score := range minMaxNormalize(vector) {
+		fused.add(vector[i], vectorWeight*score/totalWeight)
+	}
+	for i, score := range minMaxNormalize(lexical) {
+		fused.add(lexical[i], lexicalWeight*score/totalWeight)
+	}
+	return fused.sorted()
+}
+
+// minMaxNormalize 把分数线性缩放到 [0, 1]，所有分数相同时都记为 1
+func minMaxNormalize(results []RetrievalResult) []float64 {
+	scores := make([]float64, len(results))
+	if len(results) == 0 {
+		return scores
+	}
+
+	minScore, maxScore := results[0].Score, results[0].Score
+	for _, r := range results {
+		minScore = min(minScore, r.Score)
+		maxScore = max(maxScore, r.Score)
+	}
+	for i, r := range results {
+		if maxScore == minScore {
+			scores[i] = 1
+		} else {
+			scores[i] = (r.Score - minScore) / (maxScore - minScore)
+		}
+	}
+	return scores
+}
+
+// fusion 累加多路结果的融合分数
+type fusion struct {
+	order   []string
+	results map[string]RetrievalResult
+}
+
+func newFusion() *fusion {
+	return &fusion{results: make(map[string]RetrievalResult)}
+}
+
+func (f *fusion) add(result RetrievalResult, score float64) {
+	existing, ok := f.results[result.Document.ID]
+	if !ok {
+		f.order = append(f.order, result.Document.ID)
+		existing = RetrievalResult{Document: result.Document, Vector: result.Vector}
+	}
+	existing.Score += score
+	f.results[result.Document.ID] = existing
+}
+
+// sorted 按融合分数降序返回，分数相同时保持首次出现的顺序
+func (f *fusion) sorted() []RetrievalResult {
+	out := make([]RetrievalResult, len(f.order))
+	for i, id := range f.order {
+		out[i] = f.results[id]
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
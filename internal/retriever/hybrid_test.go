@@ -0,0 +1,248 @@
+package retriever
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	"goRag/internal/embedding"
+)
+
+func hitIDs(hits []SearchHit) []string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+func resultIDs(results []RetrievalResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Document.ID
+	}
+	return ids
+}
+
+func TestBM25IndexScoring(t *testing.T) {
+	tests := []struct {
+		name  string
+		docs  map[string]string
+		query string
+		want  []string
+	}{
+		{
+			name:  "higher term frequency ranks first",
+			docs:  map[string]string{"once": "alpha beta gamma delta", "twice": "alpha alpha gamma delta"},
+			query: "alpha",
+			want:  []string{"twice", "once"},
+		},
+		{
+			name:  "rare term outweighs common term",
+			docs:  map[string]string{"common": "alpha alpha beta", "rare": "alpha zeta beta", "other": "alpha beta beta"},
+			query: "alpha zeta",
+			want:  []string{"rare", "common", "other"},
+		},
+		{
+			name:  "shorter document ranks first",
+			docs:  map[string]string{"short": "alpha beta", "long": "alpha beta gamma delta epsilon zeta eta theta"},
+			query: "alpha",
+			want:  []string{"short", "long"},
+		},
+		{
+			name:  "no matching term",
+			docs:  map[string]string{"a": "alpha beta"},
+			query: "omega",
+			want:  []string{},
+		},
+		{
+			name:  "chinese bigrams",
+			docs:  map[string]string{"zh": "向量检索和关键词检索", "en": "vector search"},
+			query: "关键词",
+			want:  []string{"zh"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := NewBM25Index(0, -1)
+			for id, text := range tt.docs {
+				index.Add(id, text)
+			}
+			if got := hitIDs(index.Search(tt.query, 10, nil)); !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBM25IndexAddRemove(t *testing.T) {
+	index := NewBM25Index(1.2, 0.75)
+	index.Add("a", "alpha beta")
+	index.Add("b", "alpha gamma gamma")
+
+	// 相同 ID 再次添加时替换旧内容
+	index.Add("a", "delta")
+	if got := hitIDs(index.Search("alpha", 10, nil)); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("after replace Search(alpha) = %v, want [b]", got)
+	}
+	if index.totalLen != 4 {
+		t.Errorf("totalLen = %d, want 4", index.totalLen)
+	}
+
+	index.Remove("b")
+	index.Remove("missing")
+	if index.Len() != 1 || index.totalLen != 1 {
+		t.Errorf("after remove Len = %d, totalLen = %d, want 1, 1", index.Len(), index.totalLen)
+	}
+	if hits := index.Search("gamma", 10, nil); len(hits) != 0 {
+		t.Errorf("removed document still found: %v", hits)
+	}
+	if _, ok := index.postings["gamma"]; ok {
+		t.Error("empty posting list for gamma was not removed")
+	}
+
+	accept := func(id string) bool { return id != "a" }
+	if hits := index.Search("delta", 10, accept); len(hits) != 0 {
+		t.Errorf("filtered Search(delta) = %v, want none", hits)
+	}
+}
+
+func TestMemoryRetrieverLexicalIndexFollowsDelete(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewMemoryRetriever(embedding.NewSimpleEmbedder(16), WithLexicalIndex(NewBM25Index(1.2, 0.75)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDocuments(ctx, []Document{
+		{ID: "a", Content: "error code ERR4521 in the payment service"},
+		{ID: "b", Content: "the payment service overview"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := m.RetrieveLexical(ctx, "ERR4521", 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resultIDs(results); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("RetrieveLexical = %v, want [a]", got)
+	}
+
+	if err := m.DeleteDocument(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = m.RetrieveLexical(ctx, "ERR4521", 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("deleted document still found: %v", resultIDs(results))
+	}
+}
+
+func scored(id string, score float64) RetrievalResult {
+	return RetrievalResult{Document: Document{ID: id}, Score: score}
+}
+
+func TestFusion(t *testing.T) {
+	vector := []RetrievalResult{scored("a", 0.9), scored("b", 0.5), scored("c", 0.1)}
+	lexical := []RetrievalResult{scored("b", 10), scored("d", 2)}
+
+	tests := []struct {
+		name   string
+		fused  []RetrievalResult
+		want   []string
+		scores map[string]float64
+	}{
+		{
+			name:  "rrf",
+			fused: reciprocalRankFusion(vector, lexical, 1, 1, 60),
+			want:  []string{"b", "a", "d", "c"},
+			scores: map[string]float64{
+				"b": (1.0/62 + 1.0/61) / (2.0 / 61),
+				"a": 0.5,
+				"d": (1.0 / 62) / (2.0 / 61),
+			},
+		},
+		{
+			name:   "rrf weighted towards lexical",
+			fused:  reciprocalRankFusion(vector, lexical, 1, 3, 60),
+			want:   []string{"b", "d", "a", "c"},
+			scores: map[string]float64{"a": 0.25},
+		},
+		{
+			name:   "rrf first in both lists scores 1",
+			fused:  reciprocalRankFusion(vector[:1], vector[:1], 1, 1, 60),
+			want:   []string{"a"},
+			scores: map[string]float64{"a": 1},
+		},
+		{
+			name:   "weighted",
+			fused:  weightedFusion(vector, lexical, 1, 1),
+			want:   []string{"b", "a", "c", "d"},
+			scores: map[string]float64{"b": 0.75, "a": 0.5, "c": 0, "d": 0},
+		},
+		{
+			name:   "weighted single list",
+			fused:  weightedFusion(vector, nil, 1, 1),
+			want:   []string{"a", "b", "c"},
+			scores: map[string]float64{"a": 0.5, "b": 0.25},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resultIDs(tt.fused); !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+			for _, r := range tt.fused {
+				if r.Score < 0 || r.Score > 1 {
+					t.Errorf("score of %s = %v, want within [0, 1]", r.Document.ID, r.Score)
+				}
+				if want, ok := tt.scores[r.Document.ID]; ok && math.Abs(r.Score-want) > 1e-9 {
+					t.Errorf("score of %s = %v, want %v", r.Document.ID, r.Score, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHybridRetrieverScoresWithinUnitRange(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewMemoryRetriever(embedding.NewSimpleEmbedder(16), WithLexicalIndex(NewBM25Index(1.2, 0.75)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDocuments(ctx, []Document{
+		{ID: "a", Content: "error code ERR4521 in the payment service"},
+		{ID: "b", Content: "the payment service overview"},
+		{ID: "c", Content: "deploying the search service"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fusion := range []string{FusionRRF, FusionWeighted} {
+		t.Run(fusion, func(t *testing.T) {
+			h, err := NewHybridRetriever(m, HybridConfig{Fusion: fusion})
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := h.Retrieve(ctx, "ERR4521", 3, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Contains(resultIDs(results), "a") {
+				t.Fatalf("results = %v, want a included", resultIDs(results))
+			}
+			// 关键词检索排第一的文档至少得 0.5 分，按 rerank:0.3 之类的阈值过滤时不会被全部丢弃
+			if results[0].Score < 0.5 {
+				t.Errorf("top score = %v, want at least 0.5", results[0].Score)
+			}
+			for _, r := range results {
+				if r.Score < 0 || r.Score > 1 {
+					t.Errorf("score of %s = %v, want within [0, 1]", r.Document.ID, r.Score)
+				}
+			}
+		})
+	}
+}
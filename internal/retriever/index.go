@@ -0,0 +1,98 @@
+package retriever
+
+import (
+	"sort"
+	"sync"
+)
+
+// SearchHit 向量索引的命中结果
+type SearchHit struct {
+	ID    string
+	Score float64 // 余弦相似度，越大越相似
+}
+
+// VectorIndex 向量索引接口
+// MemoryRetriever 负责文档存储，索引只负责按向量找最近邻，可以在暴力检索和近似检索之间切换
+type VectorIndex interface {
+	// Add 添加或替换一个向量
+	Add(id string, vector []float32)
+
+	// Remove 删除一个向量
+	Remove(id string)
+
+	// Search 返回与 query 最相似的 k 个向量（按分数降序）
+	// accept 不为 nil 时只返回 accept 返回 true 的 ID（在取 topK 之前过滤）
+	Search(query []float32, k int, accept func(id string) bool) []SearchHit
+
+	// Len 返回索引中的向量数量
+	Len() int
+}
+
+// FlatIndex 暴力检索索引
+// 逐个计算余弦相似度，结果精确，适合小规模数据或作为近似索引的对照基准
+type FlatIndex struct {
+	mu      sync.RWMutex
+	vectors map[string][]float32
+}
+
+// NewFlatIndex 创建暴力检索索引
+func NewFlatIndex() *FlatIndex {
+	return &FlatIndex{
+		vectors: make(map[string][]float32),
+	}
+}
+
+// Add 添加或替换一个向量
+func (f *FlatIndex) Add(id string, vector []float32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.vectors[id] = vector
+}
+
+// Remove 删除一个向量
+func (f *FlatIndex) Remove(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.vectors, id)
+}
+
+// Search 计算所有向量的相似度并返回 topK
+func (f *FlatIndex) Search(query []float32, k int, accept func(id string) bool) []SearchHit {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if k <= 0 {
+		return nil
+	}
+
+	hits := make([]SearchHit, 0, len(f.vectors))
+	for id, vector := range f.vectors {
+		if accept != nil && !accept(id) {
+			continue
+		}
+		hits = append(hits, SearchHit{
+			ID:    id,
+			Score: cosineSimilarity(query, vector),
+		})
+	}
+
+	// 按分数降序排序，分数相同按 ID 排序保证结果稳定
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	if k < len(hits) {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// Len 返回索引中的向量数量
+func (f *FlatIndex) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.vectors)
+}
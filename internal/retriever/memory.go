@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"slices"
 	"sync"
 
+	"goRag/internal/chunker"
 	"goRag/internal/embedding"
 )
 
@@ -18,20 +20,104 @@ type MemoryRetriever struct {
 	vectors   map[string][]float32
 	embedder  embedding.Embedder
 	dimension int
+	index     VectorIndex // 向量索引，默认暴力检索
+	lexical   *BM25Index  // 可选的关键词索引，与向量索引同步维护
+
+	chunker  chunker.Splitter    // 可选的切分器，设置后文档按分块嵌入和检索
+	parents  map[string]Document // 切分前的原文档（不嵌入），用于把命中的分块扩展为更大的上下文
+	children map[string][]string // 原文档 ID -> 分块 ID
+
+	store            *FileStore // 可选的持久化存储
+	compactThreshold int        // 日志操作数达到该值时自动生成快照，0 表示不自动压缩
+}
+
+// MemoryOption 内存检索器配置项
+type MemoryOption func(*MemoryRetriever)
+
+// WithFileStore 使用文件存储持久化文档和向量
+// compactThreshold: 追加日志累计多少次操作后自动写快照（<= 0 表示只在 Snapshot/Close 时写）
+func WithFileStore(store *FileStore, compactThreshold int) MemoryOption {
+	return func(m *MemoryRetriever) {
+		m.store = store
+		m.compactThreshold = compactThreshold
+	}
+}
+
+// WithIndex 使用指定的向量索引（如 HNSWIndex）替代默认的暴力检索
+func WithIndex(index VectorIndex) MemoryOption {
+	return func(m *MemoryRetriever) {
+		m.index = index
+	}
+}
+
+// WithLexicalIndex 同时维护 BM25 关键词索引，启用后可通过 RetrieveLexical 或 HybridRetriever 使用
+func WithLexicalIndex(index *BM25Index) MemoryOption {
+	return func(m *MemoryRetriever) {
+		m.lexical = index
+	}
+}
+
+// WithChunker 添加文档时先用切分器切成分块，每个分块单独嵌入和检索
+// 分块 ID 为 "<原文档 ID>#<序号>"，元数据继承原文档并附加 parent_id、chunk_index、start、end；
+// 按原文档 ID 删除时会删除它的全部分块，重复添加同一 ID 会替换旧的分块。
+func WithChunker(splitter chunker.Splitter) MemoryOption {
+	return func(m *MemoryRetriever) {
+		m.chunker = splitter
+	}
 }
 
 // NewMemoryRetriever 创建内存检索器
-func NewMemoryRetriever(embedder embedding.Embedder) (*MemoryRetriever, error) {
+// 配置了持久化存储时会在创建时加载磁盘数据，嵌入模型或维度不一致时返回错误
+func NewMemoryRetriever(embedder embedding.Embedder, opts ...MemoryOption) (*MemoryRetriever, error) {
 	if embedder == nil {
 		return nil, fmt.Errorf("embedder cannot be nil")
 	}
 
-	return &MemoryRetriever{
+	m := &MemoryRetriever{
 		documents: make(map[string]Document),
 		vectors:   make(map[string][]float32),
 		embedder:  embedder,
 		dimension: embedder.GetDimension(),
-	}, nil
+		index:     NewFlatIndex(),
+		parents:   make(map[string]Document),
+		children:  make(map[string][]string),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.store != nil {
+		header := StoreHeader{
+			Model:     embedder.GetModelName(),
+			Dimension: m.dimension,
+		}
+		if instructed, ok := embedder.(embedding.DocumentInstructor); ok {
+			header.DocumentInstruction = instructed.DocumentInstruction()
+		}
+		documents, vectors, parents, err := m.store.Load(header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load store: %w", err)
+		}
+		m.documents = documents
+		m.vectors = vectors
+		m.parents = parents
+		for id, vector := range vectors {
+			m.index.Add(id, vector)
+		}
+		for id, doc := range documents {
+			if parentID, ok := doc.Metadata[chunker.MetadataParentID].(string); ok {
+				m.children[parentID] = append(m.children[parentID], id)
+			}
+		}
+		if m.lexical != nil {
+			for id, doc := range documents {
+				m.lexical.Add(id, doc.Content)
+			}
+		}
+		log.Printf("loaded %d documents from store", len(documents))
+	}
+
+	return m, nil
 }
 
 // cosineSimilarity 计算余弦相似度
@@ -55,13 +141,16 @@ func cosineSimilarity(a, b []float32) float64 {
 }
 
 // AddDocuments 添加文档到检索器
+// 配置了切分器时先切成分块，重复添加同一 ID 的文档会替换旧内容和旧分块
 func (m *MemoryRetriever) AddDocuments(ctx context.Context, documents []Document) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	records, parents, replaced := m.expand(documents)
+
 	// 批量嵌入文档内容
-	texts := make([]string, len(documents))
-	for i, doc := range documents {
+	texts := make([]string, len(records))
+	for i, doc := range records {
 		texts[i] = doc.Content
 	}
 
@@ -70,76 +159,258 @@ func (m *MemoryRetriever) AddDocuments(ctx context.Context, documents []Document
 		return fmt.Errorf("failed to embed documents: %w", err)
 	}
 
-	// 存储文档和向量
-	for i, doc := range documents {
+	// 被替换文档的旧分块和新分块写在同一条日志中；先写日志再更新内存，保证持久化失败时状态不变
+	var deleted []string
+	for _, parentID := range replaced {
+		deleted = append(deleted, m.removalIDsLocked(parentID)...)
+	}
+	if m.store != nil {
+		if err := m.store.AppendReplace(deleted, records, vectors, parents); err != nil {
+			return fmt.Errorf("failed to persist documents: %w", err)
+		}
+	}
+
+	// 删除旧分块，存储新的文档和向量
+	m.dropLocked(deleted)
+	for _, parent := range parents {
+		m.parents[parent.ID] = parent
+	}
+	for i, doc := range records {
 		m.documents[doc.ID] = doc
 		m.vectors[doc.ID] = vectors[i]
+		m.index.Add(doc.ID, vectors[i])
+		if m.lexical != nil {
+			m.lexical.Add(doc.ID, doc.Content)
+		}
+		if parentID, ok := doc.Metadata[chunker.MetadataParentID].(string); ok {
+			m.children[parentID] = append(m.children[parentID], doc.ID)
+		}
 	}
 
-	return nil
+	return m.maybeCompact()
+}
+
+// expand 把文档展开为实际存储的记录（未配置切分器时就是文档本身），
+// 同时返回被切分的原文档，以及需要先删除旧记录的文档 ID（调用方需持有写锁）
+// 同一批中 ID 重复的文档只保留最后一个；已有的分块、原文档和未切分的旧记录都会被替换。
+func (m *MemoryRetriever) expand(documents []Document) ([]Document, []Document, []string) {
+	documents = dedupeDocuments(documents)
+	if m.chunker == nil {
+		return documents, nil, nil
+	}
+
+	var records, parents []Document
+	var replaced []string
+	for _, doc := range documents {
+		_, hasChunks := m.children[doc.ID]
+		_, hasParent := m.parents[doc.ID]
+		// 启用切分器之前添加的文档（或内容为空时按原样保存的文档）以自身 ID 存储
+		_, hasRecord := m.documents[doc.ID]
+		if hasChunks || hasParent || hasRecord {
+			replaced = append(replaced, doc.ID)
+		}
+
+		chunks := chunker.Split(m.chunker, doc.ID, doc.Content, doc.Metadata)
+		if len(chunks) == 0 {
+			// 内容为空时按原样保存
+			records = append(records, doc)
+			continue
+		}
+		parents = append(parents, doc)
+		for _, chunk := range chunks {
+			records = append(records, Document{
+				ID:       chunk.ID,
+				Content:  chunk.Content,
+				Metadata: chunk.Metadata,
+			})
+		}
+	}
+	return records, parents, replaced
+}
+
+// dedupeDocuments 同一批中 ID 重复的文档只保留最后一个，其余文档保持原顺序
+func dedupeDocuments(documents []Document) []Document {
+	last := make(map[string]int, len(documents))
+	for i, doc := range documents {
+		last[doc.ID] = i
+	}
+	if len(last) == len(documents) {
+		return documents
+	}
+
+	deduped := make([]Document, 0, len(last))
+	for i, doc := range documents {
+		if last[doc.ID] == i {
+			deduped = append(deduped, doc)
+		}
+	}
+	return deduped
 }
 
 // DeleteDocument 删除文档
+// documentID 为切分前的原文档 ID 时删除它的全部分块
 func (m *MemoryRetriever) DeleteDocument(ctx context.Context, documentID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	delete(m.documents, documentID)
-	delete(m.vectors, documentID)
+	if err := m.removeLocked(documentID); err != nil {
+		return err
+	}
+	return m.maybeCompact()
+}
+
+// removeLocked 删除文档或原文档的全部分块，不存在时不做任何事（调用方需持有写锁）
+// 全部删除写在同一条日志中，写入成功后才更新内存
+func (m *MemoryRetriever) removeLocked(documentID string) error {
+	ids := m.removalIDsLocked(documentID)
+	if len(ids) == 0 {
+		return nil
+	}
+	if m.store != nil {
+		if err := m.store.AppendDelete(ids...); err != nil {
+			return fmt.Errorf("failed to persist deletion: %w", err)
+		}
+	}
+	m.dropLocked(ids)
+	return nil
+}
+
+// removalIDsLocked 返回删除 documentID 时需要删除的全部记录 ID：
+// 原文档为它的全部分块加上原文档本身，普通文档或单个分块为它自己，不存在时为空（调用方需持有锁）
+func (m *MemoryRetriever) removalIDsLocked(documentID string) []string {
+	ids := slices.Clone(m.children[documentID])
+	if _, ok := m.parents[documentID]; ok {
+		ids = append(ids, documentID)
+	}
+	if len(ids) == 0 {
+		if _, ok := m.documents[documentID]; ok {
+			ids = []string{documentID}
+		}
+	}
+	return ids
+}
+
+// dropLocked 从内存中删除记录（调用方需持有写锁）
+func (m *MemoryRetriever) dropLocked(ids []string) {
+	for _, id := range ids {
+		if doc, ok := m.documents[id]; ok {
+			if parentID, ok := doc.Metadata[chunker.MetadataParentID].(string); ok {
+				m.children[parentID] = removeID(m.children[parentID], id)
+				if len(m.children[parentID]) == 0 {
+					delete(m.children, parentID)
+				}
+			}
+			delete(m.documents, id)
+			delete(m.vectors, id)
+			m.index.Remove(id)
+			if m.lexical != nil {
+				m.lexical.Remove(id)
+			}
+		}
+		delete(m.parents, id)
+		delete(m.children, id)
+	}
+}
+
+// removeID 从 ID 列表中移除一个 ID
+func removeID(ids []string, id string) []string {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+// maybeCompact 日志过长时写快照（调用方需持有写锁）
+func (m *MemoryRetriever) maybeCompact() error {
+	if m.store == nil || m.compactThreshold <= 0 || m.store.LogEntries() < m.compactThreshold {
+		return nil
+	}
+	if err := m.store.Compact(m.documents, m.vectors, m.parents); err != nil {
+		return fmt.Errorf("failed to compact store: %w", err)
+	}
 	return nil
 }
 
+// Snapshot 立即把当前状态写成快照并清空追加日志
+func (m *MemoryRetriever) Snapshot() error {
+	if m.store == nil {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.store.Compact(m.documents, m.vectors, m.parents)
+}
+
+// Close 写入最终快照并关闭持久化存储
+func (m *MemoryRetriever) Close() error {
+	if m.store == nil {
+		return nil
+	}
+	if err := m.Snapshot(); err != nil {
+		return err
+	}
+	return m.store.Close()
+}
+
 // Retrieve 根据查询检索相关文档
-func (m *MemoryRetriever) Retrieve(ctx context.Context, query string, topK int) ([]RetrievalResult, error) {
+func (m *MemoryRetriever) Retrieve(ctx context.Context, query string, topK int, filter *Filter) ([]RetrievalResult, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	// 嵌入查询
-	queryVector, err := m.embedder.EmbedText(ctx, query)
+	// 嵌入查询（使用查询模式，文档在 AddDocuments 中使用文档模式）
+	queryVector, err := m.embedder.EmbedQuery(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
 	log.Println("query vector: ", queryVector)
 
-	// 计算所有文档的相似度
-	type scoreDoc struct {
-		doc   Document
-		score float64
-	}
+	// 通过向量索引查找最相似的 topK 个文档
+	hits := m.index.Search(queryVector, topK, m.acceptFunc(filter))
+	return m.toResults(hits), nil
+}
 
-	scores := make([]scoreDoc, 0, len(m.documents))
-	for id, doc := range m.documents {
-		vector := m.vectors[id]
-		score := cosineSimilarity(queryVector, vector)
-		scores = append(scores, scoreDoc{
-			doc:   doc,
-			score: score,
-		})
-		// log the scores and the document id
-		log.Println("score: ", score, "document id: ", id)
+// RetrieveLexical 使用 BM25 关键词索引检索（需要通过 WithLexicalIndex 启用）
+func (m *MemoryRetriever) RetrieveLexical(ctx context.Context, query string, topK int, filter *Filter) ([]RetrievalResult, error) {
+	if m.lexical == nil {
+		return nil, fmt.Errorf("lexical index is not enabled")
 	}
 
-	// 按分数排序（降序）
-	for i := 0; i < len(scores)-1; i++ {
-		for j := i + 1; j < len(scores); j++ {
-			if scores[i].score < scores[j].score {
-				scores[i], scores[j] = scores[j], scores[i]
-			}
-		}
-	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	// 取 topK
-	if topK > len(scores) {
-		topK = len(scores)
+	hits := m.lexical.Search(query, topK, m.acceptFunc(filter))
+	return m.toResults(hits), nil
+}
+
+// acceptFunc 把元数据过滤条件转换为索引使用的 ID 判定函数（调用方需持有读锁）
+// 元数据过滤在索引内部、取 topK 之前生效
+func (m *MemoryRetriever) acceptFunc(filter *Filter) func(id string) bool {
+	if filter == nil {
+		return nil
+	}
+	return func(id string) bool {
+		doc, ok := m.documents[id]
+		return ok && filter.Match(doc.Metadata)
 	}
+}
 
-	results := make([]RetrievalResult, topK)
-	for i := 0; i < topK; i++ {
-		results[i] = RetrievalResult{
-			Document: scores[i].doc,
-			Score:    scores[i].score,
+// toResults 把索引命中转换为检索结果（调用方需持有读锁）
+func (m *MemoryRetriever) toResults(hits []SearchHit) []RetrievalResult {
+	results := make([]RetrievalResult, 0, len(hits))
+	for _, hit := range hits {
+		doc, ok := m.documents[hit.ID]
+		if !ok {
+			continue
 		}
+		results = append(results, RetrievalResult{
+			Document: doc,
+			Score:    hit.Score,
+			Vector:   m.vectors[hit.ID],
+		})
 	}
-
-	return results, nil
+	return results
 }
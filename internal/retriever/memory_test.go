@@ -0,0 +1,233 @@
+package retriever
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"goRag/internal/chunker"
+	"goRag/internal/embedding"
+)
+
+// newChunkedRetriever 创建按 20 字符切分、持久化到 dir 的检索器
+func newChunkedRetriever(t *testing.T, dir string) (*MemoryRetriever, *FileStore) {
+	t.Helper()
+	splitter, err := chunker.NewRecursiveSplitter(20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMemoryRetriever(embedding.NewSimpleEmbedder(16), WithChunker(splitter), WithFileStore(store, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, store
+}
+
+func TestMemoryRetrieverReplaceIsAtomicInLog(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	long := strings.Repeat("alpha beta gamma. ", 4)
+
+	m, store := newChunkedRetriever(t, dir)
+	if err := m.AddDocuments(ctx, []Document{{ID: "doc", Content: long}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.children["doc"]) < 3 {
+		t.Fatalf("expected at least 3 chunks, got %v", m.children["doc"])
+	}
+	if err := m.AddDocuments(ctx, []Document{{ID: "doc", Content: "short"}}); err != nil {
+		t.Fatal(err)
+	}
+	// 替换只写一条日志：删除旧分块和添加新分块在同一行
+	if entries := store.LogEntries(); entries != 2 {
+		t.Errorf("log entries = %d, want 2", entries)
+	}
+	store.Close()
+
+	m, store = newChunkedRetriever(t, dir)
+	defer store.Close()
+	if len(m.documents) != 1 || m.documents[chunker.ChunkID("doc", 0)].Content != "short" {
+		t.Errorf("documents after reload = %v, want only the replacement chunk", m.documents)
+	}
+	if got := m.children["doc"]; len(got) != 1 {
+		t.Errorf("children after reload = %v, want 1 chunk", got)
+	}
+	if m.parents["doc"].Content != "short" {
+		t.Errorf("parent after reload = %q, want %q", m.parents["doc"].Content, "short")
+	}
+}
+
+func TestMemoryRetrieverReplaceKeepsStateWhenPersistFails(t *testing.T) {
+	ctx := context.Background()
+	long := strings.Repeat("alpha beta gamma. ", 4)
+
+	m, store := newChunkedRetriever(t, t.TempDir())
+	if err := m.AddDocuments(ctx, []Document{{ID: "doc", Content: long}}); err != nil {
+		t.Fatal(err)
+	}
+	chunks := len(m.children["doc"])
+
+	// 关闭存储使日志写入失败
+	store.Close()
+	if err := m.AddDocuments(ctx, []Document{{ID: "doc", Content: "short"}}); err == nil {
+		t.Fatal("expected persist error")
+	}
+	if len(m.children["doc"]) != chunks || len(m.documents) != chunks || m.parents["doc"].Content != long {
+		t.Errorf("state changed after failed replace: %d chunks, %d documents", len(m.children["doc"]), len(m.documents))
+	}
+	if err := m.DeleteDocument(ctx, "doc"); err == nil {
+		t.Fatal("expected persist error")
+	}
+	if len(m.documents) != chunks {
+		t.Errorf("state changed after failed delete: %d documents", len(m.documents))
+	}
+}
+
+func TestMemoryRetrieverDeleteParent(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	m, store := newChunkedRetriever(t, dir)
+	err := m.AddDocuments(ctx, []Document{
+		{ID: "a", Content: strings.Repeat("alpha beta gamma. ", 3)},
+		{ID: "b", Content: "bravo"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteDocument(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	m, store = newChunkedRetriever(t, dir)
+	defer store.Close()
+	if _, ok := m.parents["a"]; ok {
+		t.Error("deleted parent reloaded")
+	}
+	if len(m.documents) != 1 || len(m.children["a"]) != 0 {
+		t.Errorf("documents after reload = %v, want only b's chunk", m.documents)
+	}
+}
+
+func TestMemoryRetrieverReplacesRecordAddedBeforeChunking(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// 未配置切分器时文档以自身 ID 存储
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewMemoryRetriever(embedding.NewSimpleEmbedder(16), WithFileStore(store, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.AddDocuments(ctx, []Document{{ID: "doc", Content: "old unchunked content"}}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	m, store := newChunkedRetriever(t, dir)
+	if err := m.AddDocuments(ctx, []Document{{ID: "doc", Content: strings.Repeat("alpha beta gamma. ", 3)}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.documents["doc"]; ok {
+		t.Error("old unchunked record was not replaced")
+	}
+	chunks := len(m.children["doc"])
+	store.Close()
+
+	m, store = newChunkedRetriever(t, dir)
+	defer store.Close()
+	if _, ok := m.documents["doc"]; ok {
+		t.Error("old unchunked record reloaded")
+	}
+	if len(m.documents) != chunks || len(m.children["doc"]) != chunks {
+		t.Errorf("documents after reload = %d, children = %d, want %d", len(m.documents), len(m.children["doc"]), chunks)
+	}
+}
+
+func TestMemoryRetrieverDuplicateIDsInBatch(t *testing.T) {
+	ctx := context.Background()
+	long := strings.Repeat("alpha beta gamma. ", 3)
+
+	m, store := newChunkedRetriever(t, t.TempDir())
+	defer store.Close()
+	err := m.AddDocuments(ctx, []Document{
+		{ID: "doc", Content: long},
+		{ID: "other", Content: "bravo"},
+		{ID: "doc", Content: "short"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只保留最后一个同 ID 文档
+	if got := m.children["doc"]; len(got) != 1 || got[0] != chunker.ChunkID("doc", 0) {
+		t.Errorf("children = %v, want only the chunk of the last duplicate", got)
+	}
+	if m.parents["doc"].Content != "short" || m.documents[chunker.ChunkID("doc", 0)].Content != "short" {
+		t.Errorf("parent = %q, want %q", m.parents["doc"].Content, "short")
+	}
+	if len(m.documents) != 2 {
+		t.Errorf("documents = %d, want 2", len(m.documents))
+	}
+
+	unchunked, err := NewMemoryRetriever(embedding.NewSimpleEmbedder(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unchunked.AddDocuments(ctx, []Document{{ID: "a", Content: "first"}, {ID: "a", Content: "second"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(unchunked.documents) != 1 || unchunked.documents["a"].Content != "second" {
+		t.Errorf("documents = %v, want only the last duplicate", unchunked.documents)
+	}
+}
+
+func TestMemoryRetrieverRecordsDocumentInstruction(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	open := func(document string) (*MemoryRetriever, *FileStore, error) {
+		embedder, err := embedding.NewInstructionEmbedder(embedding.NewSimpleEmbedder(16), embedding.Instruction{Query: "query: ", Document: document})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store, err := OpenFileStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := NewMemoryRetriever(embedder, WithFileStore(store, 0))
+		return m, store, err
+	}
+
+	m, store, err := open("passage: ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDocuments(ctx, []Document{{ID: "a", Content: "alpha"}}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// 文档前缀变化后旧向量不可比，拒绝加载；查询前缀不影响已保存的向量
+	_, store, err = open("search_document: ")
+	store.Close()
+	if err == nil {
+		t.Fatal("expected an error when the document instruction changes")
+	}
+	m, store, err = open("passage: ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if len(m.documents) != 1 {
+		t.Errorf("documents after reload = %d, want 1", len(m.documents))
+	}
+}
@@ -0,0 +1,167 @@
+package retriever
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"goRag/internal/chunker"
+)
+
+// ExpandWhole 扩展窗口取该值时返回整篇原文档
+const ExpandWhole = -1
+
+// chunkGapSeparator 同一原文档中不相邻的几段上下文之间的分隔
+const chunkGapSeparator = "\n\n……\n\n"
+
+// Expander 可以把命中的小分块扩展为更大上下文的检索器（small-to-big）
+type Expander interface {
+	// Expand 把检索结果中的分块扩展为原文档或相邻分块窗口，并按原文档去重
+	// window 为 ExpandWhole 时返回整篇原文档，n > 0 时返回命中分块及前后各 n 个相邻分块，0 时不扩展
+	Expand(ctx context.Context, results []RetrievalResult, window int) ([]RetrievalResult, error)
+}
+
+// Expand 实现 Expander
+//
+// 同一原文档的多个分块命中时合并为一条结果：位置取排名最靠前的分块，分数取最高分，
+// 向量取最高分分块的向量；各命中分块的窗口重叠或相邻时合并为连续的一段原文，否则用省略号分隔。
+// 结果的 ID 和元数据为原文档的 ID 和元数据。不是分块的结果原样保留。
+func (m *MemoryRetriever) Expand(ctx context.Context, results []RetrievalResult, window int) ([]RetrievalResult, error) {
+	if window == 0 || len(results) == 0 {
+		return results, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// 按原文档分组，记录每组第一次出现的位置
+	type group struct {
+		position int
+		best     RetrievalResult
+		hits     []int // 命中分块的序号
+	}
+	groups := make(map[string]*group)
+	expanded := make([]RetrievalResult, 0, len(results))
+	for _, result := range results {
+		parentID, ok := result.Document.Metadata[chunker.MetadataParentID].(string)
+		if !ok {
+			expanded = append(expanded, result)
+			continue
+		}
+
+		g, seen := groups[parentID]
+		if !seen {
+			g = &group{position: len(expanded), best: result}
+			groups[parentID] = g
+			expanded = append(expanded, RetrievalResult{}) // 占位，稍后填充
+		}
+		if result.Score > g.best.Score {
+			g.best = result
+		}
+		g.hits = append(g.hits, metadataInt(result.Document.Metadata, chunker.MetadataChunkIndex))
+	}
+
+	for parentID, g := range groups {
+		expanded[g.position] = m.expandGroup(parentID, g.best, g.hits, window)
+	}
+	return expanded, nil
+}
+
+// expandGroup 把同一原文档的命中分块扩展为一条结果（调用方需持有读锁）
+func (m *MemoryRetriever) expandGroup(parentID string, best RetrievalResult, hits []int, window int) RetrievalResult {
+	parent, hasParent := m.parents[parentID]
+	if !hasParent {
+		// 没有保存原文档（如旧数据）时，用分块内容拼接
+		parent = Document{ID: parentID, Metadata: parentMetadata(best.Document.Metadata)}
+	}
+
+	result := RetrievalResult{
+		Document: Document{ID: parentID, Metadata: parent.Metadata},
+		Score:    best.Score,
+		Vector:   best.Vector,
+	}
+
+	if window == ExpandWhole && hasParent {
+		result.Document.Content = parent.Content
+		return result
+	}
+
+	chunks := m.sortedChunks(parentID)
+	if window == ExpandWhole {
+		window = len(chunks)
+	}
+
+	// 计算每个命中分块的窗口 [from, to]，合并重叠或相邻的窗口
+	type chunkRange struct{ from, to int }
+	var ranges []chunkRange
+	sort.Ints(hits)
+	for _, hit := range hits {
+		r := chunkRange{from: max(hit-window, 0), to: min(hit+window, len(chunks)-1)}
+		if n := len(ranges); n > 0 && r.from <= ranges[n-1].to+1 {
+			ranges[n-1].to = max(ranges[n-1].to, r.to)
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r.from > r.to {
+			continue
+		}
+		first, last := chunks[r.from], chunks[r.to]
+		start := metadataInt(first.Metadata, chunker.MetadataStart)
+		end := metadataInt(last.Metadata, chunker.MetadataEnd)
+		if hasParent && start >= 0 && start < end && end <= len(parent.Content) {
+			// 直接截取原文，相邻分块的重叠部分不会重复
+			parts = append(parts, parent.Content[start:end])
+			continue
+		}
+		contents := make([]string, 0, r.to-r.from+1)
+		for _, chunk := range chunks[r.from : r.to+1] {
+			contents = append(contents, chunk.Content)
+		}
+		parts = append(parts, strings.Join(contents, "\n"))
+	}
+	if len(parts) == 0 {
+		// 原文档和分块都已被删除（如并发删除），退回命中的分块本身
+		return best
+	}
+	result.Document.Content = strings.Join(parts, chunkGapSeparator)
+	return result
+}
+
+// sortedChunks 返回原文档的全部分块，按分块序号排序（调用方需持有读锁）
+func (m *MemoryRetriever) sortedChunks(parentID string) []Document {
+	chunks := make([]Document, 0, len(m.children[parentID]))
+	for _, id := range m.children[parentID] {
+		if doc, ok := m.documents[id]; ok {
+			chunks = append(chunks, doc)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		return metadataInt(chunks[i].Metadata, chunker.MetadataChunkIndex) < metadataInt(chunks[j].Metadata, chunker.MetadataChunkIndex)
+	})
+	return chunks
+}
+
+// metadataInt 读取整数元数据（从 JSON 加载后为 float64），不存在时返回 -1
+func metadataInt(metadata map[string]interface{}, key string) int {
+	if v, ok := toFloat(metadata[key]); ok {
+		return int(v)
+	}
+	return -1
+}
+
+// parentMetadata 从分块元数据中去掉分块相关的字段，还原原文档的元数据
+func parentMetadata(metadata map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		switch k {
+		case chunker.MetadataParentID, chunker.MetadataChunkIndex, chunker.MetadataStart, chunker.MetadataEnd, chunker.MetadataSection:
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package retriever
+
+import (
+	"context"
+	"testing"
+
+	"goRag/internal/chunker"
+)
+
+// chunkResult 把检索器中的分块包装为检索结果
+func chunkResult(t *testing.T, m *MemoryRetriever, parentID string, index int) RetrievalResult {
+	t.Helper()
+	doc, ok := m.documents[chunker.ChunkID(parentID, index)]
+	if !ok {
+		t.Fatalf("chunk %d of %s not found", index, parentID)
+	}
+	return RetrievalResult{Document: doc, Score: float64(10 - index)}
+}
+
+func TestMemoryRetrieverExpandAfterReload(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	content := "one two three four. five six seven. eight nine ten. eleven twelve. thirteen fourteen. fifteen sixteen."
+
+	m, store := newChunkedRetriever(t, dir)
+	err := m.AddDocuments(ctx, []Document{{ID: "doc", Content: content, Metadata: map[string]interface{}{"source": "test"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.children["doc"]); n != 6 {
+		t.Fatalf("got %d chunks, want 6", n)
+	}
+
+	tests := []struct {
+		name     string
+		hits     []int
+		window   int
+		expected string
+	}{
+		{"neighbours", []int{1}, 1, "one two three four. five six seven. eight nine ten."},
+		{"merged windows", []int{1, 3}, 1, "one two three four. five six seven. eight nine ten. eleven twelve. thirteen fourteen."},
+		{"gap", []int{0, 5}, 1, "one two three four. five six seven." + chunkGapSeparator + "thirteen fourteen. fifteen sixteen."},
+		{"whole", []int{2}, ExpandWhole, content},
+	}
+	check := func(t *testing.T, m *MemoryRetriever) {
+		t.Helper()
+		for _, tt := range tests {
+			results := make([]RetrievalResult, len(tt.hits))
+			for i, hit := range tt.hits {
+				results[i] = chunkResult(t, m, "doc", hit)
+			}
+			expanded, err := m.Expand(ctx, results, tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(expanded) != 1 {
+				t.Fatalf("%s: got %d results, want 1", tt.name, len(expanded))
+			}
+			got := expanded[0]
+			if got.Document.ID != "doc" || got.Document.Content != tt.expected {
+				t.Errorf("%s: expanded to %s %q, want doc %q", tt.name, got.Document.ID, got.Document.Content, tt.expected)
+			}
+			if got.Document.Metadata["source"] != "test" {
+				t.Errorf("%s: metadata = %v, want the parent metadata", tt.name, got.Document.Metadata)
+			}
+		}
+	}
+	check(t, m)
+	store.Close()
+
+	m, store = newChunkedRetriever(t, dir)
+	defer store.Close()
+	// 从 JSON 加载后整数元数据变为 float64，扩展结果应与重启前一致
+	if _, ok := m.documents[chunker.ChunkID("doc", 1)].Metadata[chunker.MetadataChunkIndex].(float64); !ok {
+		t.Fatalf("chunk_index after reload is %T, want float64", m.documents[chunker.ChunkID("doc", 1)].Metadata[chunker.MetadataChunkIndex])
+	}
+	if m.parents["doc"].Content != content {
+		t.Fatalf("parent after reload = %q, want %q", m.parents["doc"].Content, content)
+	}
+	check(t, m)
+}
@@ -2,6 +2,7 @@ package retriever
 
 import (
 	"context"
+	"fmt"
 )
 
 // Document 文档结构
@@ -15,12 +16,14 @@ type Document struct {
 type RetrievalResult struct {
 	Document Document
 	Score    float64
+	Vector   []float32 // 文档的嵌入向量（供排序阶段使用，检索器不支持时为空）
 }
 
 // Retriever 检索器接口
 type Retriever interface {
 	// Retrieve 根据查询检索相关文档
-	Retrieve(ctx context.Context, query string, topK int) ([]RetrievalResult, error)
+	// filter 为 nil 时不做元数据过滤，否则只在满足条件的文档中取 topK
+	Retrieve(ctx context.Context, query string, topK int, filter *Filter) ([]RetrievalResult, error)
 
 	// AddDocuments 添加文档到检索器
 	AddDocuments(ctx context.Context, documents []Document) error
@@ -42,8 +45,19 @@ func NewService(retriever Retriever) *Service {
 }
 
 // Retrieve 检索文档
-func (s *Service) Retrieve(ctx context.Context, query string, topK int) ([]RetrievalResult, error) {
-	return s.retriever.Retrieve(ctx, query, topK)
+func (s *Service) Retrieve(ctx context.Context, query string, topK int, filter *Filter) ([]RetrievalResult, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return s.retriever.Retrieve(ctx, query, topK, filter)
+}
+
+// Expand 把命中的分块扩展为原文档或相邻分块窗口（见 Expander），检索器不支持时原样返回
+func (s *Service) Expand(ctx context.Context, results []RetrievalResult, window int) ([]RetrievalResult, error) {
+	if expander, ok := s.retriever.(Expander); ok && window != 0 {
+		return expander.Expand(ctx, results, window)
+	}
+	return results, nil
 }
 
 // AddDocuments 添加文档
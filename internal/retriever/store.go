@@ -0,0 +1,412 @@
+package retriever
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	storeFormatVersion = 1
+	snapshotFileName   = "snapshot.jsonl"
+	logFileName        = "wal.jsonl"
+
+	opAdd    = "add"
+	opDelete = "delete"
+)
+
+// ErrStoreMismatch 磁盘数据与当前嵌入模型（名称、维度或文档指令前缀）不一致
+var ErrStoreMismatch = errors.New("store was written by a different embedding model")
+
+// StoreHeader 持久化文件头，记录写入时使用的嵌入模型
+// DocumentInstruction 为嵌入文档时添加的指令前缀，前缀不同时向量不可混用；
+// 查询前缀只影响查询向量，不影响已存储的文档向量，因此不记录。
+type StoreHeader struct {
+	Version             int    `json:"version"`
+	Model               string `json:"model"`
+	Dimension           int    `json:"dimension"`
+	DocumentInstruction string `json:"document_instruction,omitempty"`
+}
+
+// storedDocument 磁盘上的文档记录（文档 + 向量）
+// Parent 为 true 的记录是切分前的原文档，没有向量
+type storedDocument struct {
+	ID       string                 `json:"id"`
+	Content  string                 `json:"content"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Vector   []float32              `json:"vector"`
+	Parent   bool                   `json:"parent,omitempty"`
+}
+
+// logEntry 追加日志中的一条操作记录
+// Deleted 为同一条记录中先删除的 ID：替换已有文档时删除旧分块和添加新分块写在同一行，
+// 中途崩溃时要么都生效要么都不生效。
+type logEntry struct {
+	Op        string           `json:"op"`
+	Documents []storedDocument `json:"documents,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Deleted   []string         `json:"deleted,omitempty"`
+}
+
+// FileStore 基于文件的持久化存储
+// 目录下包含两个文件：
+//   - snapshot.jsonl：快照，第一行为文件头，之后每行一个文档
+//   - wal.jsonl：追加日志，第一行为文件头，之后每行一个 AddDocuments/DeleteDocument 操作
+//
+// 启动时先加载快照再回放日志；Compact 会把当前状态写成新快照并清空日志。
+type FileStore struct {
+	mu      sync.Mutex
+	dir     string
+	header  StoreHeader
+	logFile *os.File
+	entries int // 自上次快照以来日志中的操作数
+}
+
+// OpenFileStore 打开（或创建）持久化目录
+func OpenFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("store directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Load 加载快照并回放追加日志，返回文档、向量和切分前的原文档
+// header 描述当前嵌入模型，与磁盘记录不一致时返回 ErrStoreMismatch
+func (s *FileStore) Load(header StoreHeader) (map[string]Document, map[string][]float32, map[string]Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	header.Version = storeFormatVersion
+	s.header = header
+
+	documents := make(map[string]Document)
+	vectors := make(map[string][]float32)
+	parents := make(map[string]Document)
+
+	apply := func(doc storedDocument) error {
+		if doc.Parent {
+			parents[doc.ID] = Document{ID: doc.ID, Content: doc.Content, Metadata: doc.Metadata}
+			return nil
+		}
+		if len(doc.Vector) != header.Dimension {
+			return fmt.Errorf("%w: document %s has dimension %d, expected %d",
+				ErrStoreMismatch, doc.ID, len(doc.Vector), header.Dimension)
+		}
+		documents[doc.ID] = Document{ID: doc.ID, Content: doc.Content, Metadata: doc.Metadata}
+		vectors[doc.ID] = doc.Vector
+		return nil
+	}
+
+	// 1. 加载快照
+	_, _, err := s.readFile(snapshotFileName, func(line []byte) error {
+		var doc storedDocument
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return err
+		}
+		return apply(doc)
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	// 2. 回放日志
+	s.entries = 0
+	logSize, torn, err := s.readFile(logFileName, func(line []byte) error {
+		var entry logEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		remove := func(id string) {
+			delete(documents, id)
+			delete(vectors, id)
+			delete(parents, id)
+		}
+		switch entry.Op {
+		case opAdd:
+			for _, id := range entry.Deleted {
+				remove(id)
+			}
+			for _, doc := range entry.Documents {
+				if err := apply(doc); err != nil {
+					return err
+				}
+			}
+		case opDelete:
+			if entry.ID != "" {
+				remove(entry.ID)
+			}
+			for _, id := range entry.Deleted {
+				remove(id)
+			}
+		default:
+			return fmt.Errorf("unknown log operation %q", entry.Op)
+		}
+		s.entries++
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to replay log: %w", err)
+	}
+
+	// 3. 截掉末尾不完整的记录，否则后续追加的记录会接在残缺的一行后面，下次启动时一起被丢弃
+	if torn {
+		if err := os.Truncate(filepath.Join(s.dir, logFileName), logSize); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to truncate log: %w", err)
+		}
+	}
+
+	// 4. 打开日志用于后续追加
+	if err := s.openLog(false); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return documents, vectors, parents, nil
+}
+
+// readFile 逐行读取文件，校验文件头后对每条记录调用 fn
+// 文件不存在时直接返回；末尾不完整的一行（写入中途崩溃）会被忽略，此时 torn 为 true，
+// size 为完整记录的总字节数
+func (s *FileStore) readFile(name string, fn func(line []byte) error) (size int64, torn bool, err error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	first := true
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return size, false, err
+		}
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("store: ignoring truncated trailing record in %s", name)
+			}
+			return size, len(line) > 0, nil
+		}
+		if first {
+			if err := s.checkHeader(line); err != nil {
+				return size, false, err
+			}
+			first = false
+		} else if err := fn(line); err != nil {
+			return size, false, err
+		}
+		size += int64(len(line))
+	}
+}
+
+// checkHeader 校验文件头与当前嵌入模型是否一致
+func (s *FileStore) checkHeader(line []byte) error {
+	var header StoreHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return fmt.Errorf("invalid header: %w", err)
+	}
+	if header.Version != storeFormatVersion {
+		return fmt.Errorf("unsupported store version %d", header.Version)
+	}
+	if header.Model != s.header.Model || header.Dimension != s.header.Dimension {
+		return fmt.Errorf("%w: stored %s/%d, current %s/%d", ErrStoreMismatch,
+			header.Model, header.Dimension, s.header.Model, s.header.Dimension)
+	}
+	if header.DocumentInstruction != s.header.DocumentInstruction {
+		return fmt.Errorf("%w: stored document instruction %q, current %q", ErrStoreMismatch,
+			header.DocumentInstruction, s.header.DocumentInstruction)
+	}
+	return nil
+}
+
+// openLog 打开追加日志，truncate 为 true 时清空已有内容
+func (s *FileStore) openLog(truncate bool) error {
+	if s.logFile != nil {
+		s.logFile.Close()
+		s.logFile = nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, logFileName), flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log: %w", err)
+	}
+	if info.Size() == 0 {
+		if err := writeJSONLine(f, s.header); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write log header: %w", err)
+		}
+	}
+
+	s.logFile = f
+	return nil
+}
+
+// AppendAdd 记录一次添加操作，parents 为同时添加的切分前原文档（可以为空）
+func (s *FileStore) AppendAdd(documents []Document, vectors [][]float32, parents []Document) error {
+	return s.AppendReplace(nil, documents, vectors, parents)
+}
+
+// AppendReplace 在同一条日志中记录先删除 deleted、再添加文档，回放时保证两者同时生效
+func (s *FileStore) AppendReplace(deleted []string, documents []Document, vectors [][]float32, parents []Document) error {
+	entry := logEntry{Op: opAdd, Deleted: deleted, Documents: make([]storedDocument, 0, len(documents)+len(parents))}
+	for _, doc := range parents {
+		entry.Documents = append(entry.Documents, storedDocument{
+			ID:       doc.ID,
+			Content:  doc.Content,
+			Metadata: doc.Metadata,
+			Parent:   true,
+		})
+	}
+	for i, doc := range documents {
+		entry.Documents = append(entry.Documents, storedDocument{
+			ID:       doc.ID,
+			Content:  doc.Content,
+			Metadata: doc.Metadata,
+			Vector:   vectors[i],
+		})
+	}
+	return s.append(entry)
+}
+
+// AppendDelete 在同一条日志中记录删除一个或多个文档（如原文档和它的全部分块）
+func (s *FileStore) AppendDelete(documentIDs ...string) error {
+	if len(documentIDs) == 1 {
+		return s.append(logEntry{Op: opDelete, ID: documentIDs[0]})
+	}
+	return s.append(logEntry{Op: opDelete, Deleted: documentIDs})
+}
+
+// append 写入一条日志并刷盘
+func (s *FileStore) append(entry logEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.logFile == nil {
+		return fmt.Errorf("store is not loaded")
+	}
+	if err := writeJSONLine(s.logFile, entry); err != nil {
+		return fmt.Errorf("failed to append log: %w", err)
+	}
+	if err := s.logFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	s.entries++
+	return nil
+}
+
+// LogEntries 返回自上次快照以来的日志操作数
+func (s *FileStore) LogEntries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries
+}
+
+// Compact 将当前全部文档（含原文档）写成新快照并清空追加日志
+// 快照先写入临时文件再原子替换，中途失败不会破坏已有数据
+func (s *FileStore) Compact(documents map[string]Document, vectors map[string][]float32, parents map[string]Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.header.Version == 0 {
+		return fmt.Errorf("store is not loaded")
+	}
+
+	tmpPath := filepath.Join(s.dir, snapshotFileName+".tmp")
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	writer := bufio.NewWriter(f)
+	err = writeJSONLine(writer, s.header)
+	for id, doc := range parents {
+		if err != nil {
+			break
+		}
+		err = writeJSONLine(writer, storedDocument{
+			ID:       id,
+			Content:  doc.Content,
+			Metadata: doc.Metadata,
+			Parent:   true,
+		})
+	}
+	for id, doc := range documents {
+		if err != nil {
+			break
+		}
+		err = writeJSONLine(writer, storedDocument{
+			ID:       id,
+			Content:  doc.Content,
+			Metadata: doc.Metadata,
+			Vector:   vectors[id],
+		})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, snapshotFileName)); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+
+	if err := s.openLog(true); err != nil {
+		return err
+	}
+	s.entries = 0
+	return nil
+}
+
+// Close 关闭存储
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.logFile == nil {
+		return nil
+	}
+	err := s.logFile.Close()
+	s.logFile = nil
+	return err
+}
+
+// writeJSONLine 以 JSON Lines 格式写入一条记录
+func writeJSONLine(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,97 @@
+package retriever
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreTruncatesTornLogRecord(t *testing.T) {
+	dir := t.TempDir()
+	header := StoreHeader{Model: "test-model", Dimension: 2}
+
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := store.Load(header); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendAdd([]Document{{ID: "a", Content: "alpha"}}, [][]float32{{1, 0}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// 模拟写入中途崩溃：日志末尾留下半行记录
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"op":"add","documents":[{"id":"b"`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents, _, _, err := store.Load(header)
+	if err != nil {
+		t.Fatalf("Load with torn record: %v", err)
+	}
+	if len(documents) != 1 {
+		t.Fatalf("expected 1 document after dropping torn record, got %d", len(documents))
+	}
+	if err := store.AppendAdd([]Document{{ID: "c", Content: "gamma"}}, [][]float32{{0, 1}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// 残缺记录被截掉后，新追加的记录在下次启动时应能正常回放
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	documents, _, _, err = store.Load(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, ok := documents[id]; !ok {
+			t.Errorf("document %q missing after reload", id)
+		}
+	}
+	if _, ok := documents["b"]; ok {
+		t.Errorf("torn document %q should not be loaded", "b")
+	}
+}
+
+func TestFileStoreRejectsDifferentDocumentInstruction(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := StoreHeader{Model: "nomic-embed-text", Dimension: 2, DocumentInstruction: "search_document: "}
+	if _, _, _, err := store.Load(header); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendAdd([]Document{{ID: "a", Content: "alpha"}}, [][]float32{{1, 0}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	header.DocumentInstruction = ""
+	if _, _, _, err := store.Load(header); !errors.Is(err, ErrStoreMismatch) {
+		t.Fatalf("expected ErrStoreMismatch, got %v", err)
+	}
+}
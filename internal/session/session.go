@@ -0,0 +1,250 @@
+package session
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"goRag/internal/llm"
+)
+
+// ErrNotFound 会话不存在
+var ErrNotFound = errors.New("session not found")
+
+const (
+	// DefaultMaxMessages 默认每个会话保留的消息数
+	DefaultMaxMessages = 50
+	// DefaultMaxSessions 默认内存中最多保留的会话数
+	DefaultMaxSessions = 1000
+	// DefaultIdleTTL 默认的会话闲置过期时间
+	DefaultIdleTTL = 24 * time.Hour
+)
+
+// Session 一次多轮对话
+type Session struct {
+	ID        string
+	Messages  []llm.Message // 按时间顺序排列的 user / assistant 消息
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Store 会话存储接口
+// Get 和 List 返回的是副本，调用方修改不会影响存储中的数据
+type Store interface {
+	// Create 创建空会话
+	Create(ctx context.Context) (*Session, error)
+
+	// Get 获取会话，不存在时返回 ErrNotFound
+	Get(ctx context.Context, id string) (*Session, error)
+
+	// Append 向会话追加消息，不存在时返回 ErrNotFound
+	Append(ctx context.Context, id string, messages ...llm.Message) error
+
+	// List 分页列出会话，按最近更新时间倒序，返回从 offset 开始的最多 limit 个会话和会话总数
+	// limit <= 0 表示不限制条数
+	List(ctx context.Context, offset, limit int) ([]*Session, int, error)
+
+	// Delete 删除会话，不存在时返回 ErrNotFound
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore 基于内存的会话存储
+// 会话数超过上限时淘汰最久未使用（Get 或 Append）的会话，闲置超过 idleTTL 的会话视为已过期
+type MemoryStore struct {
+	mu          sync.Mutex
+	lru         *list.List               // 值为 *memoryEntry，最近使用的在前
+	sessions    map[string]*list.Element // 会话 ID -> lru 中的元素
+	maxMessages int                      // 每个会话最多保留的消息数，超出时丢弃最早的消息；<= 0 表示不限制
+	maxSessions int                      // 最多保留的会话数；<= 0 表示不限制
+	idleTTL     time.Duration            // 会话闲置多久后过期；<= 0 表示不过期
+	now         func() time.Time
+}
+
+// memoryEntry 内存存储中的一个会话
+type memoryEntry struct {
+	session  *Session
+	lastUsed time.Time
+}
+
+// MemoryStoreOption 内存会话存储配置项
+type MemoryStoreOption func(*MemoryStore)
+
+// WithMaxSessions 设置最多保留的会话数，超出时淘汰最久未使用的会话，<= 0 表示不限制
+func WithMaxSessions(n int) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.maxSessions = n
+	}
+}
+
+// WithIdleTTL 设置会话闲置过期时间，<= 0 表示不过期
+func WithIdleTTL(ttl time.Duration) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.idleTTL = ttl
+	}
+}
+
+// NewMemoryStore 创建内存会话存储，默认最多保留 DefaultMaxSessions 个会话，闲置 DefaultIdleTTL 后过期
+func NewMemoryStore(maxMessages int, opts ...MemoryStoreOption) *MemoryStore {
+	s := &MemoryStore{
+		lru:         list.New(),
+		sessions:    make(map[string]*list.Element),
+		maxMessages: maxMessages,
+		maxSessions: DefaultMaxSessions,
+		idleTTL:     DefaultIdleTTL,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Create 创建空会话
+func (s *MemoryStore) Create(ctx context.Context) (*Session, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.expireLocked(now)
+	session := &Session{
+		ID:        id,
+		Messages:  []llm.Message{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.sessions[id] = s.lru.PushFront(&memoryEntry{session: session, lastUsed: now})
+	for s.maxSessions > 0 && s.lru.Len() > s.maxSessions {
+		s.removeLocked(s.lru.Back())
+	}
+
+	return session.clone(), nil
+}
+
+// Get 获取会话
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.touchLocked(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return entry.session.clone(), nil
+}
+
+// Append 向会话追加消息
+func (s *MemoryStore) Append(ctx context.Context, id string, messages ...llm.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.touchLocked(id)
+	if !ok {
+		return ErrNotFound
+	}
+
+	session := entry.session
+	session.Messages = append(session.Messages, messages...)
+	if s.maxMessages > 0 && len(session.Messages) > s.maxMessages {
+		session.Messages = append([]llm.Message(nil), session.Messages[len(session.Messages)-s.maxMessages:]...)
+	}
+	session.UpdatedAt = entry.lastUsed
+	return nil
+}
+
+// List 分页列出会话
+func (s *MemoryStore) List(ctx context.Context, offset, limit int) ([]*Session, int, error) {
+	s.mu.Lock()
+	s.expireLocked(s.now())
+	all := make([]*Session, 0, len(s.sessions))
+	for e := s.lru.Front(); e != nil; e = e.Next() {
+		all = append(all, e.Value.(*memoryEntry).session)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].UpdatedAt.After(all[j].UpdatedAt)
+	})
+
+	total := len(all)
+	page := all[min(max(offset, 0), total):]
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+	}
+	sessions := make([]*Session, len(page))
+	for i, session := range page {
+		sessions[i] = session.clone()
+	}
+	s.mu.Unlock()
+
+	return sessions, total, nil
+}
+
+// Delete 删除会话
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(s.now())
+	e, ok := s.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.removeLocked(e)
+	return nil
+}
+
+// touchLocked 清理过期会话后查找会话，并把它标记为最近使用（调用方需持有锁）
+func (s *MemoryStore) touchLocked(id string) (*memoryEntry, bool) {
+	now := s.now()
+	s.expireLocked(now)
+	e, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*memoryEntry)
+	entry.lastUsed = now
+	s.lru.MoveToFront(e)
+	return entry, true
+}
+
+// expireLocked 删除闲置超过 idleTTL 的会话（调用方需持有锁）
+// lru 按最近使用排序，从队尾开始删除，遇到未过期的会话即停止
+func (s *MemoryStore) expireLocked(now time.Time) {
+	if s.idleTTL <= 0 {
+		return
+	}
+	for e := s.lru.Back(); e != nil && now.Sub(e.Value.(*memoryEntry).lastUsed) > s.idleTTL; e = s.lru.Back() {
+		s.removeLocked(e)
+	}
+}
+
+// removeLocked 从存储中删除会话（调用方需持有锁）
+func (s *MemoryStore) removeLocked(e *list.Element) {
+	s.lru.Remove(e)
+	delete(s.sessions, e.Value.(*memoryEntry).session.ID)
+}
+
+// clone 复制会话，避免调用方持有内部切片
+func (s *Session) clone() *Session {
+	c := *s
+	c.Messages = append([]llm.Message(nil), s.Messages...)
+	return &c
+}
+
+// newID 生成随机会话 ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,156 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"goRag/internal/llm"
+)
+
+// fakeClock 手动推进的测试时钟
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// newTestStore 创建使用测试时钟的内存存储
+func newTestStore(opts ...MemoryStoreOption) (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore(4, opts...)
+	s.now = clock.now
+	return s, clock
+}
+
+// mustCreate 创建会话并推进时钟，使各会话的时间不同
+func mustCreate(t *testing.T, s *MemoryStore, clock *fakeClock) string {
+	t.Helper()
+	session, err := s.Create(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Second)
+	return session.ID
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(WithMaxSessions(2), WithIdleTTL(0))
+
+	a := mustCreate(t, s, clock)
+	b := mustCreate(t, s, clock)
+	// 访问 a 后 b 成为最久未使用的会话
+	if _, err := s.Get(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	c := mustCreate(t, s, clock)
+
+	if _, err := s.Get(ctx, b); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(b) error = %v, want ErrNotFound", err)
+	}
+	for _, id := range []string{a, c} {
+		if _, err := s.Get(ctx, id); err != nil {
+			t.Errorf("Get(%s) error = %v", id, err)
+		}
+	}
+}
+
+func TestMemoryStoreExpiresIdleSessions(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(WithMaxSessions(0), WithIdleTTL(time.Minute))
+
+	idle := mustCreate(t, s, clock)
+	active := mustCreate(t, s, clock)
+	clock.advance(40 * time.Second)
+	if err := s.Append(ctx, active, llm.Message{Role: "user", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(30 * time.Second)
+
+	if _, err := s.Get(ctx, idle); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(idle) error = %v, want ErrNotFound", err)
+	}
+	session, err := s.Get(ctx, active)
+	if err != nil {
+		t.Fatalf("Get(active) error = %v", err)
+	}
+	if len(session.Messages) != 1 {
+		t.Errorf("active session has %d messages, want 1", len(session.Messages))
+	}
+	if _, total, _ := s.List(ctx, 0, 0); total != 1 {
+		t.Errorf("List total = %d, want 1", total)
+	}
+}
+
+func TestMemoryStoreListPagination(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore()
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, mustCreate(t, s, clock))
+	}
+	// 更新最早的会话，使它排在最前
+	if err := s.Append(ctx, ids[0], llm.Message{Role: "user", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	order := []string{ids[0], ids[4], ids[3], ids[2], ids[1]}
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		expected      []string
+	}{
+		{"all", 0, 0, order},
+		{"first page", 0, 2, order[:2]},
+		{"second page", 2, 2, order[2:4]},
+		{"last page", 4, 2, order[4:]},
+		{"offset past end", 10, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, total, err := s.List(ctx, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 5 {
+				t.Errorf("total = %d, want 5", total)
+			}
+			if len(sessions) != len(tt.expected) {
+				t.Fatalf("got %d sessions, want %d", len(sessions), len(tt.expected))
+			}
+			for i, session := range sessions {
+				if session.ID != tt.expected[i] {
+					t.Errorf("session %d = %s, want %s", i, session.ID, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTrimsMessages(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore()
+	id := mustCreate(t, s, clock)
+
+	for _, content := range []string{"1", "2", "3", "4", "5", "6"} {
+		if err := s.Append(ctx, id, llm.Message{Role: "user", Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Messages) != 4 || session.Messages[0].Content != "3" {
+		t.Errorf("messages = %v, want the last 4", session.Messages)
+	}
+}
@@ -0,0 +1,107 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Counter token 计数器
+// 用于估算文本占用的模型上下文长度。HeuristicCounter 不依赖具体模型的词表，
+// 需要精确计数时可以用 CounterFunc 接入模型自带的分词器（如 tiktoken）。
+type Counter interface {
+	// Count 返回文本的 token 数
+	Count(text string) int
+}
+
+// CounterFunc 把普通函数适配为 Counter
+type CounterFunc func(text string) int
+
+// Count 实现 Counter
+func (f CounterFunc) Count(text string) int {
+	return f(text)
+}
+
+// HeuristicCounter 启发式 token 计数器
+//
+// 规则（偏保守，宁可多估）：
+//   - 中日韩文字每个字记 1 个 token
+//   - 拉丁字母和数字的连续片段每 4 个字符记 1 个 token（不足 4 个按 1 个）
+//   - 其他非空白字符（标点、符号、emoji）每个记 1 个 token
+//   - 空白不计
+type HeuristicCounter struct{}
+
+// NewHeuristicCounter 创建启发式 token 计数器
+func NewHeuristicCounter() *HeuristicCounter {
+	return &HeuristicCounter{}
+}
+
+// Count 估算文本的 token 数
+func (h *HeuristicCounter) Count(text string) int {
+	count := 0
+	wordLen := 0
+	flushWord := func() {
+		count += (wordLen + 3) / 4
+		wordLen = 0
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			count++
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			wordLen++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			// 其他文字（西里尔、希腊字母、带重音的拉丁字母等）按 2 个字符 1 个 token
+			wordLen += 2
+		case unicode.IsSpace(r):
+			flushWord()
+		default:
+			flushWord()
+			count++
+		}
+	}
+	flushWord()
+
+	return count
+}
+
+// sentenceTerminators 句末标点（中英文）
+const sentenceTerminators = "。！？!?；;…"
+
+// SplitSentences 按句末标点（。！？!?；; 以及换行）切分句子
+// 标点和紧随其后的空白、右引号、右括号归入前一句，所有片段拼接起来等于原文。
+// 英文句号只有后面跟着空白或在结尾时才视为句末，避免切开 "3.14"、"e.g." 中间。
+func SplitSentences(text string) []string {
+	runes := []rune(text)
+	var sentences []string
+	start := 0
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		end := false
+		switch {
+		case r == '\n':
+			end = true
+		case strings.ContainsRune(sentenceTerminators, r):
+			end = true
+		case r == '.':
+			end = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		}
+		if !end {
+			continue
+		}
+
+		// 吸收连续的句末标点、右引号、右括号和空白
+		for i+1 < len(runes) && (strings.ContainsRune(sentenceTerminators+".\n”’\"')）」』", runes[i+1]) || unicode.IsSpace(runes[i+1])) {
+			i++
+		}
+		sentences = append(sentences, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+
+	return sentences
+}
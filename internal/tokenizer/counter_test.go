@@ -0,0 +1,73 @@
+package tokenizer
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHeuristicCounterCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"hello", 2},
+		{"你好世界", 4},
+		{"hello 世界", 4},
+		{"a, b.", 4},
+		{"2024年", 2},
+		{"  \n\t", 0},
+		{"café", 2}, // é 按 2 个字符计
+		{"ＧＯ", 1},   // 全角字母按非 ASCII 文字计
+		{"好！", 2},   // 全角标点记 1 个 token
+		{"👍ok", 2},
+	}
+	counter := NewHeuristicCounter()
+	for _, tt := range tests {
+		if got := counter.Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCounterFunc(t *testing.T) {
+	var counter Counter = CounterFunc(func(text string) int {
+		return len(strings.Fields(text))
+	})
+	if got := counter.Count("one two three"); got != 3 {
+		t.Errorf("Count = %d, want 3", got)
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"chinese", "你好。世界！", []string{"你好。", "世界！"}},
+		{"english", "Hello world. How are you? Fine", []string{"Hello world. ", "How are you? ", "Fine"}},
+		{"decimal point", "Pi is 3.14 today.", []string{"Pi is 3.14 today."}},
+		{"abbreviation", "e.g. this", []string{"e.g. ", "this"}},
+		{"closing quote", "他说：“好的。”然后走了", []string{"他说：“好的。”", "然后走了"}},
+		{"closing bracket", "（注：见上文。）下一句", []string{"（注：见上文。）", "下一句"}},
+		{"repeated terminators", "真的吗？！是的", []string{"真的吗？！", "是的"}},
+		{"semicolon and ellipsis", "第一；第二…第三", []string{"第一；", "第二…", "第三"}},
+		{"newline", "line1\nline2", []string{"line1\n", "line2"}},
+		{"no terminator", "没有句末标点", []string{"没有句末标点"}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitSentences(tt.text)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SplitSentences(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if joined := strings.Join(got, ""); joined != tt.text {
+				t.Errorf("joined sentences = %q, want original %q", joined, tt.text)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenizer 分词器接口
+// 嵌入器和关键词索引都通过它把文本切成词，保证两边对中文的处理方式一致
+type Tokenizer interface {
+	// Tokenize 把文本切分为词序列
+	Tokenize(text string) []string
+}
+
+// CJKMode 中日韩文字的切分方式
+type CJKMode int
+
+const (
+	// ModeBigram 相邻两个字组成一个词（"检索增强" -> "检索" "索增" "增强"），孤立的单字保留为单字
+	ModeBigram CJKMode = iota
+	// ModeUnigram 每个字单独成词
+	ModeUnigram
+	// ModeUnigramBigram 同时输出单字和双字，召回更高但词数更多
+	ModeUnigramBigram
+)
+
+// Config 分词器配置
+type Config struct {
+	CJKMode   CJKMode  // 中日韩文字的切分方式
+	StopWords []string // 停用词，匹配的词会被丢弃（可使用 DefaultStopWords）
+}
+
+// CJKTokenizer 支持中日韩文字的分词器
+//
+// 规则：
+//   - 拉丁字母和数字的连续片段作为一个词，统一转小写
+//   - 全角字母数字（如 "ＧＯ１２"）先折叠为半角
+//   - 中日韩文字按 CJKMode 切分为单字/双字（不依赖词典）
+//   - 空白、半角和全角标点（，。！？、；：“”（）《》等）都作为分隔符
+type CJKTokenizer struct {
+	mode      CJKMode
+	stopWords map[string]bool
+}
+
+// NewCJKTokenizer 创建分词器
+func NewCJKTokenizer(config Config) *CJKTokenizer {
+	var stopWords map[string]bool
+	if len(config.StopWords) > 0 {
+		stopWords = make(map[string]bool, len(config.StopWords))
+		for _, w := range config.StopWords {
+			stopWords[strings.ToLower(w)] = true
+		}
+	}
+	return &CJKTokenizer{
+		mode:      config.CJKMode,
+		stopWords: stopWords,
+	}
+}
+
+// Default 默认分词器：中文双字切分，不过滤停用词
+func Default() *CJKTokenizer {
+	return NewCJKTokenizer(Config{CJKMode: ModeBigram})
+}
+
+// Tokenize 把文本切分为词序列
+func (t *CJKTokenizer) Tokenize(text string) []string {
+	tokens := make([]string, 0, len(text)/2)
+
+	var latin strings.Builder // 当前拉丁字母/数字片段
+	var cjk []rune            // 当前中日韩文字片段
+
+	flushLatin := func() {
+		if latin.Len() > 0 {
+			tokens = t.appendToken(tokens, latin.String())
+			latin.Reset()
+		}
+	}
+	flushCJK := func() {
+		if len(cjk) > 0 {
+			tokens = t.appendCJK(tokens, cjk)
+			cjk = cjk[:0]
+		}
+	}
+
+	for _, r := range text {
+		r = foldWidth(r)
+		switch {
+		case isCJK(r):
+			flushLatin()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			flushCJK()
+			latin.WriteRune(unicode.ToLower(r))
+		default:
+			// 空白、标点、符号都是分隔符
+			flushLatin()
+			flushCJK()
+		}
+	}
+	flushLatin()
+	flushCJK()
+
+	return tokens
+}
+
+// appendCJK 按配置的模式切分一段连续的中日韩文字
+func (t *CJKTokenizer) appendCJK(tokens []string, run []rune) []string {
+	if t.mode == ModeUnigram || t.mode == ModeUnigramBigram || len(run) == 1 {
+		for _, r := range run {
+			tokens = t.appendToken(tokens, string(r))
+		}
+	}
+	if t.mode == ModeBigram || t.mode == ModeUnigramBigram {
+		for i := 0; i+1 < len(run); i++ {
+			tokens = t.appendToken(tokens, string(run[i:i+2]))
+		}
+	}
+	return tokens
+}
+
+// appendToken 过滤停用词后追加
+func (t *CJKTokenizer) appendToken(tokens []string, token string) []string {
+	if t.stopWords[token] {
+		return tokens
+	}
+	return append(tokens, token)
+}
+
+// isCJK 判断是否为中日韩文字（汉字、假名、韩文）
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// foldWidth 把全角 ASCII 字符（U+FF01-U+FF5E）和全角空格折叠为半角
+func foldWidth(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E:
+		return r - 0xFEE0
+	case r == 0x3000:
+		return ' '
+	default:
+		return r
+	}
+}
+
+// DefaultStopWords 常用中英文停用词
+func DefaultStopWords() []string {
+	return []string{
+		// 中文
+		"的", "了", "和", "是", "在", "就", "都", "而", "及", "与", "着", "或",
+		"一个", "没有", "我们", "你们", "他们", "这个", "那个", "什么", "怎么",
+		"吗", "呢", "吧", "啊", "也", "很", "这", "那", "之", "于", "其",
+		// 英文
+		"a", "an", "the", "and", "or", "of", "to", "in", "on", "for", "with",
+		"is", "are", "was", "were", "be", "by", "as", "at", "it", "this", "that",
+	}
+}
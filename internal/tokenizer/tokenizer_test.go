@@ -0,0 +1,36 @@
+package tokenizer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCJKTokenizerTokenize(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		text   string
+		want   []string
+	}{
+		{"chinese bigrams", Config{}, "检索增强", []string{"检索", "索增", "增强"}},
+		{"single character kept", Config{}, "第3章", []string{"第", "3", "章"}},
+		{"mixed latin and chinese", Config{}, "RAG检索增强生成2024", []string{"rag", "检索", "索增", "增强", "强生", "生成", "2024"}},
+		{"latin words and digits", Config{}, "GPT-4o 和 Go1.25", []string{"gpt", "4o", "和", "go1", "25"}},
+		{"full-width letters and digits", Config{}, "ＧＯ１２，你好！", []string{"go12", "你好"}},
+		{"full-width space", Config{}, "你　好", []string{"你", "好"}},
+		{"chinese punctuation separates", Config{}, "检索。增强、生成（RAG）", []string{"检索", "增强", "生成", "rag"}},
+		{"kana", Config{}, "カタカナ", []string{"カタ", "タカ", "カナ"}},
+		{"unigram", Config{CJKMode: ModeUnigram}, "检索增强", []string{"检", "索", "增", "强"}},
+		{"unigram and bigram", Config{CJKMode: ModeUnigramBigram}, "检索", []string{"检", "索", "检索"}},
+		{"stop words", Config{StopWords: DefaultStopWords()}, "The RAG 的 system", []string{"rag", "system"}},
+		{"empty", Config{}, "", []string{}},
+		{"only punctuation", Config{}, "，。！?", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewCJKTokenizer(tt.config).Tokenize(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}